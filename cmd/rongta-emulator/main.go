@@ -0,0 +1,113 @@
+// Command rongta-emulator emulates a network Rongta RP326 on a raw TCP port.
+//
+// Every connection gets its own virtual printer. Status requests are
+// answered, and each job (split at paper cuts) is saved to the output
+// directory as a text transcript and the raw bytes received.
+//
+//	rongta-emulator -listen :9100 -out ./jobs
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/cyb3rjerry/rongta-escpos/rongtasim"
+)
+
+var jobCount atomic.Uint64
+
+func main() {
+	listen := flag.String("listen", ":9100", "address to listen on")
+	out := flag.String("out", "jobs", "directory the finished jobs are written to")
+	flag.Parse()
+
+	if err := os.MkdirAll(*out, 0o755); err != nil {
+		log.Fatal(err)
+	}
+
+	ln, err := net.Listen("tcp", *listen)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("listening on %s, writing jobs to %s", ln.Addr(), *out)
+
+	log.Fatal(run(ln, *out))
+}
+
+// Serves a virtual printer on every connection accepted from ln, saving
+// the jobs to the out directory
+// Returns when ln fails or is closed.
+func run(ln net.Listener, out string) error {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return err
+		}
+
+		go serve(conn, out)
+	}
+}
+
+func serve(conn net.Conn, out string) {
+	defer conn.Close()
+	log.Printf("%s: connected", conn.RemoteAddr())
+
+	sim := rongtasim.New()
+	defer sim.Close()
+
+	// Replies go back to the host as soon as they are produced
+	go io.Copy(conn, sim)
+
+	buf := make([]byte, 4096)
+	for {
+		n, err := conn.Read(buf)
+		if n > 0 {
+			sim.Write(buf[:n])
+
+			for _, job := range sim.TakeJobs() {
+				save(conn, out, job)
+			}
+		}
+
+		if err != nil {
+			break
+		}
+	}
+
+	// Whatever was sent after the last cut still makes a job
+	if job, ok := sim.EndJob(); ok {
+		save(conn, out, job)
+	}
+
+	log.Printf("%s: disconnected", conn.RemoteAddr())
+}
+
+func save(conn net.Conn, out string, job rongtasim.Job) {
+	name := fmt.Sprintf("job-%s-%04d", time.Now().Format("20060102-150405"), jobCount.Add(1))
+	path := filepath.Join(out, name)
+
+	text := strings.Join(job.Lines, "\n")
+	if len(job.Lines) > 0 {
+		text += "\n"
+	}
+
+	if err := os.WriteFile(path+".txt", []byte(text), 0o644); err != nil {
+		log.Printf("%s: %v", conn.RemoteAddr(), err)
+		return
+	}
+
+	if err := os.WriteFile(path+".bin", job.Raw, 0o644); err != nil {
+		log.Printf("%s: %v", conn.RemoteAddr(), err)
+		return
+	}
+
+	log.Printf("%s: saved %s (%d lines, %d bytes)", conn.RemoteAddr(), name, len(job.Lines), len(job.Raw))
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cyb3rjerry/rongta-escpos/commands"
+)
+
+func TestEmulator(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip(err)
+	}
+	defer ln.Close()
+
+	out := t.TempDir()
+	go run(ln, out)
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	job := []byte("hello\nworld\n\x1bi")
+	if _, err := conn.Write(job); err != nil {
+		t.Fatal(err)
+	}
+
+	// The printer answers status requests
+	if _, err := conn.Write([]byte{commands.DLE, commands.EOT, 0x01}); err != nil {
+		t.Fatal(err)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+
+	status := make([]byte, 1)
+	if _, err := conn.Read(status); err != nil {
+		t.Fatal(err)
+	}
+
+	if !commands.IsRealTimeStatus(status[0]) {
+		t.Errorf("got status % x, want a real-time status", status)
+	}
+
+	// The job is saved once its cut has been processed
+	var bins []string
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		bins, _ = filepath.Glob(filepath.Join(out, "*.bin"))
+		if len(bins) > 0 {
+			break
+		}
+	}
+
+	if len(bins) != 1 {
+		t.Fatalf("saved %d jobs, want 1", len(bins))
+	}
+
+	raw, err := os.ReadFile(bins[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(raw, job) {
+		t.Errorf("saved raw bytes % x, want % x", raw, job)
+	}
+
+	text, err := os.ReadFile(strings.TrimSuffix(bins[0], ".bin") + ".txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(text) != "hello\nworld\n" {
+		t.Errorf("saved transcript %q, want the printed lines", text)
+	}
+
+	// The status request sent after the cut is saved as a last job on
+	// disconnect; wait for it so nothing is written once the test is over
+	conn.Close()
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		bins, _ = filepath.Glob(filepath.Join(out, "*.bin"))
+		if len(bins) == 2 {
+			break
+		}
+	}
+
+	if len(bins) != 2 {
+		t.Fatalf("saved %d jobs after disconnecting, want 2", len(bins))
+	}
+}
@@ -0,0 +1,93 @@
+// Command rongta is a toolbox for working with Rongta printer data.
+//
+// The dump subcommand disassembles raw ESC/POS, one command per line with
+// its offset. With -capture, the file is read as a session capture and
+// the bytes written to the printer are disassembled.
+//
+//	rongta dump job.bin
+//	rongta dump -capture session.jsonl
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/cyb3rjerry/rongta-escpos/commands"
+	"github.com/cyb3rjerry/rongta-escpos/rongta"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "dump":
+		err = dump(os.Args[2:])
+	default:
+		usage()
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "rongta:", err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: rongta dump [-capture] file")
+	os.Exit(2)
+}
+
+func dump(args []string) error {
+	flags := flag.NewFlagSet("dump", flag.ExitOnError)
+	capture := flags.Bool("capture", false, "read a session capture instead of raw bytes")
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		usage()
+	}
+
+	f, err := os.Open(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	data, err := readData(f, *capture)
+	if err != nil {
+		return err
+	}
+
+	cmds, decodeErr := commands.Decode(data)
+	for _, cmd := range cmds {
+		fmt.Printf("%08x  %s\n", cmd.Offset, cmd)
+	}
+
+	return decodeErr
+}
+
+// Returns the bytes sent to the printer
+func readData(r io.Reader, capture bool) ([]byte, error) {
+	if !capture {
+		return io.ReadAll(r)
+	}
+
+	records, err := rongta.ReadCapture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var data bytes.Buffer
+	for _, record := range records {
+		if record.Dir == rongta.DirWrite {
+			data.Write(record.Data)
+		}
+	}
+
+	return data.Bytes(), nil
+}
@@ -0,0 +1,202 @@
+package commands
+
+import "fmt"
+
+// Automatic Status Back settings for SetASB
+type ASBFlags uint8
+
+const (
+	ASBDrawer        ASBFlags = 0x01 // Drawer kick-out connector pin 3
+	ASBOnlineOffline ASBFlags = 0x02
+	ASBError         ASBFlags = 0x04
+	ASBPaperSensor   ASBFlags = 0x08 // Roll paper sensor
+
+	ASBAll = ASBDrawer | ASBOnlineOffline | ASBError | ASBPaperSensor
+)
+
+const (
+	// ASB frame bitmasks, first byte
+	ASB_DRAWER_MASK      uint8 = 0x04 // 0 = Pin 3 LOW, 4 = Pin 3 HIGH
+	ASB_OFFLINE_MASK     uint8 = 0x08
+	ASB_COVER_MASK       uint8 = 0x20
+	ASB_FEED_BUTTON_MASK uint8 = 0x40 // Paper is being fed by the feed button
+
+	// Second byte
+	ASB_ERROR_MASK                 uint8 = 0x04 // Recoverable error
+	ASB_AUTOCUTTER_MASK            uint8 = 0x08
+	ASB_UNRECOVERABLE_ERROR_MASK   uint8 = 0x20
+	ASB_AUTORECOVERABLE_ERROR_MASK uint8 = 0x40
+
+	// Third byte
+	ASB_PAPER_NEAR_END_MASK uint8 = 0x03
+	ASB_PAPER_END_MASK      uint8 = 0x0C
+)
+
+// Enable Automatic Status Back for the given conditions, 0 disables it
+// The printer sends a frame as soon as ASB is enabled, then on every change.
+func (p *Driver) SetASB(flags ASBFlags) error {
+	return p.ToggleASB(uint8(flags))
+}
+
+// Decodes the printer state carried by an ASB frame
+// The fourth byte holds no status.
+func (f ASBFrame) Status() Status {
+	return Status{
+		DrawerPinHigh:   f[0]&ASB_DRAWER_MASK != 0,
+		Offline:         f[0]&ASB_OFFLINE_MASK != 0,
+		CoverOpen:       f[0]&ASB_COVER_MASK != 0,
+		FeedingByButton: f[0]&ASB_FEED_BUTTON_MASK != 0,
+
+		Error:                f[1]&ASB_ERROR_MASK != 0,
+		CutterError:          f[1]&ASB_AUTOCUTTER_MASK != 0,
+		UnrecoverableError:   f[1]&ASB_UNRECOVERABLE_ERROR_MASK != 0,
+		AutoRecoverableError: f[1]&ASB_AUTORECOVERABLE_ERROR_MASK != 0,
+
+		PaperNearEnd: f[2]&ASB_PAPER_NEAR_END_MASK != 0,
+		PaperEnd:     f[2]&ASB_PAPER_END_MASK != 0,
+	}
+}
+
+type StatusEvent uint8
+
+const (
+	EventOffline StatusEvent = iota + 1
+	EventOnline
+	EventCoverOpened
+	EventCoverClosed
+	EventPaperNearEnd
+	EventPaperOut
+	EventPaperOK
+	EventError
+	EventCutterError
+	EventUnrecoverableError
+	EventAutoRecoverableError
+	EventRecovered    // Every error condition cleared
+	EventDrawerOpened // Pin 3 went HIGH, which most drawers report as open
+	EventDrawerClosed
+	EventFeedStarted // Feed button paper feed
+	EventFeedStopped
+)
+
+func (e StatusEvent) String() string {
+	switch e {
+	case EventOffline:
+		return "offline"
+	case EventOnline:
+		return "online"
+	case EventCoverOpened:
+		return "cover opened"
+	case EventCoverClosed:
+		return "cover closed"
+	case EventPaperNearEnd:
+		return "paper near end"
+	case EventPaperOut:
+		return "paper out"
+	case EventPaperOK:
+		return "paper ok"
+	case EventError:
+		return "error"
+	case EventCutterError:
+		return "cutter error"
+	case EventUnrecoverableError:
+		return "unrecoverable error"
+	case EventAutoRecoverableError:
+		return "auto-recoverable error"
+	case EventRecovered:
+		return "recovered"
+	case EventDrawerOpened:
+		return "drawer opened"
+	case EventDrawerClosed:
+		return "drawer closed"
+	case EventFeedStarted:
+		return "feed started"
+	case EventFeedStopped:
+		return "feed stopped"
+	}
+
+	return fmt.Sprintf("StatusEvent(%d)", uint8(e))
+}
+
+// Status transition, Status is the state after the change
+type StatusChange struct {
+	Event  StatusEvent
+	Status Status
+}
+
+func (c StatusChange) String() string {
+	return fmt.Sprintf("%s (%s)", c.Event, c.Status)
+}
+
+// Lists the events leading from prev to next
+func statusEvents(prev, next Status) []StatusEvent {
+	var events []StatusEvent
+
+	edge := func(was, is bool, set, cleared StatusEvent) {
+		switch {
+		case !was && is && set != 0:
+			events = append(events, set)
+		case was && !is && cleared != 0:
+			events = append(events, cleared)
+		}
+	}
+
+	edge(prev.Offline, next.Offline, EventOffline, EventOnline)
+	edge(prev.CoverOpen, next.CoverOpen, EventCoverOpened, EventCoverClosed)
+
+	if paper := next.Paper(); paper != prev.Paper() {
+		switch paper {
+		case PaperStatusOut:
+			events = append(events, EventPaperOut)
+		case PaperStatusLow:
+			events = append(events, EventPaperNearEnd)
+		default:
+			events = append(events, EventPaperOK)
+		}
+	}
+
+	edge(prev.Error, next.Error, EventError, 0)
+	edge(prev.CutterError, next.CutterError, EventCutterError, 0)
+	edge(prev.UnrecoverableError, next.UnrecoverableError, EventUnrecoverableError, 0)
+	edge(prev.AutoRecoverableError, next.AutoRecoverableError, EventAutoRecoverableError, 0)
+	edge(prev.failed(), next.failed(), 0, EventRecovered)
+
+	edge(prev.DrawerPinHigh, next.DrawerPinHigh, EventDrawerOpened, EventDrawerClosed)
+	edge(prev.FeedingByButton, next.FeedingByButton, EventFeedStarted, EventFeedStopped)
+
+	return events
+}
+
+// Reports whether any error condition is set
+func (s Status) failed() bool {
+	return s.Error || s.CutterError || s.UnrecoverableError || s.AutoRecoverableError
+}
+
+// Subscribe to status changes pushed by ASB
+// ASB has to be enabled with SetASB. The first frame is compared with an
+// idle printer, so conditions already present are reported once. The
+// channel is closed by the returned cancel function or when the driver is
+// closed. Events are dropped while the channel is full.
+func (p *Driver) SubscribeStatus() (<-chan StatusChange, func()) {
+	frames, cancel := p.SubscribeASB()
+	ch := make(chan StatusChange, 16)
+
+	go func() {
+		defer close(ch)
+
+		var last Status
+		for f := range frames {
+			status := f.Status()
+
+			for _, e := range statusEvents(last, status) {
+				select {
+				case ch <- StatusChange{Event: e, Status: status}:
+				default:
+				}
+			}
+
+			last = status
+		}
+	}()
+
+	return ch, cancel
+}
@@ -0,0 +1,155 @@
+package commands_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cyb3rjerry/rongta-escpos/commands"
+	"github.com/cyb3rjerry/rongta-escpos/commands/commandstest"
+)
+
+// ASB frame of an idle printer
+var asbIdle = []byte{commands.ASB_HEADER_FIXED_BITS, 0x00, 0x00, 0x00}
+
+// DLE EOT 2 reply with the cover open
+const statusCoverOpen = statusOK | commands.COVER_STATUS_MASK
+
+func TestSetASB(t *testing.T) {
+	d, conn := commandstest.NewDriver(t)
+
+	if err := d.SetASB(commands.ASBAll); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := d.SetASB(0); err != nil {
+		t.Fatal(err)
+	}
+
+	conn.ExpectCommand(t, "ToggleASB", 15)
+	conn.ExpectCommand(t, "ToggleASB", 0)
+	conn.ExpectNoMoreCommands(t)
+}
+
+func TestASBFrameStatus(t *testing.T) {
+	for _, tt := range []struct {
+		frame commands.ASBFrame
+		want  commands.Status
+	}{
+		{commands.ASBFrame{0x10, 0x00, 0x00, 0x00}, commands.Status{}},
+		{commands.ASBFrame{0x18, 0x00, 0x00, 0x00}, commands.Status{Offline: true}},
+		{commands.ASBFrame{0x34, 0x00, 0x00, 0x00}, commands.Status{CoverOpen: true, DrawerPinHigh: true}},
+		{commands.ASBFrame{0x10, 0x08, 0x00, 0x00}, commands.Status{CutterError: true}},
+		{commands.ASBFrame{0x10, 0x00, 0x03, 0x00}, commands.Status{PaperNearEnd: true}},
+		{commands.ASBFrame{0x10, 0x00, 0x0F, 0x00}, commands.Status{PaperNearEnd: true, PaperEnd: true}},
+	} {
+		if got := tt.frame.Status(); got != tt.want {
+			t.Errorf("frame % x decoded as %s, want %s", tt.frame[:], got, tt.want)
+		}
+	}
+}
+
+func TestSubscribeASB(t *testing.T) {
+	d, conn := commandstest.NewDriver(t)
+
+	frames, cancel := d.SubscribeASB()
+	defer cancel()
+
+	conn.Inject(0x30, 0x00, 0x0C, 0x00)
+
+	select {
+	case f := <-frames:
+		if f != (commands.ASBFrame{0x30, 0x00, 0x0C, 0x00}) {
+			t.Errorf("got frame % x", f[:])
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no frame received")
+	}
+}
+
+func TestASBFrameBeforeQueryReply(t *testing.T) {
+	d, conn := commandstest.NewDriver(t)
+
+	frames, cancel := d.SubscribeASB()
+	defer cancel()
+
+	// The printer pushes a frame just before answering
+	conn.Reply("TransmitRealTimeStatus", append(append([]byte{}, asbIdle...), statusCoverOpen)...)
+
+	open, err := withTimeout(t, d, time.Second).GetCoverStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !open {
+		t.Error("cover reported closed")
+	}
+
+	select {
+	case f := <-frames:
+		if f != commands.ASBFrame(asbIdle) {
+			t.Errorf("got frame % x", f[:])
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no frame received")
+	}
+}
+
+func TestSubscribeStatus(t *testing.T) {
+	d, conn := commandstest.NewDriver(t)
+
+	changes, cancel := d.SubscribeStatus()
+	defer cancel()
+
+	next := func() commands.StatusChange {
+		t.Helper()
+
+		select {
+		case c := <-changes:
+			return c
+		case <-time.After(time.Second):
+			t.Fatal("no status change received")
+		}
+
+		return commands.StatusChange{}
+	}
+
+	// Conditions present in the first frame are reported once
+	conn.Inject(0x30, 0x00, 0x03, 0x00)
+
+	for _, want := range []commands.StatusEvent{commands.EventCoverOpened, commands.EventPaperNearEnd} {
+		if c := next(); c.Event != want {
+			t.Errorf("got %s, want %s", c, want)
+		}
+	}
+
+	// An identical frame reports nothing, the next change does
+	conn.Inject(0x30, 0x00, 0x03, 0x00)
+	conn.Inject(asbIdle...)
+
+	for _, want := range []commands.StatusEvent{commands.EventCoverClosed, commands.EventPaperOK} {
+		c := next()
+		if c.Event != want {
+			t.Errorf("got %s, want %s", c, want)
+		}
+
+		if c.Status != (commands.Status{}) {
+			t.Errorf("got status %s with %s, want idle", c.Status, c.Event)
+		}
+	}
+}
+
+func TestStatusSubscriptionEndsOnClose(t *testing.T) {
+	d, _ := commandstest.NewDriver(t)
+
+	changes, _ := d.SubscribeStatus()
+	d.Close()
+
+	select {
+	case _, ok := <-changes:
+		if ok {
+			t.Error("status change received after Close")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("subscription still open after Close")
+	}
+}
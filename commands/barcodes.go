@@ -30,10 +30,9 @@ var (
 // n = 1: Font B
 func (p *Driver) SelectFontForHRICharacters(n uint8) error {
 	if n != 0 && n != 1 {
-		return ErrinvalidHRICharacterFont
+		return argError("SelectFontForHRICharacters", "n", n, "0 or 1", ErrinvalidHRICharacterFont)
 	}
-	_, err := p.rwc.Write([]byte{GS, 'f', n})
-	return err
+	return p.exec("SelectFontForHRICharacters", []byte{GS, 'f', n})
 }
 
 // Selects the printing position of HRI characters when printing
@@ -43,8 +42,7 @@ func (p *Driver) SelectFontForHRICharacters(n uint8) error {
 // n = 2: Below the bar code
 // n = 3: Both above and below the bar code
 func (p *Driver) SelectHRICharacterPrintPosition(n uint8) error {
-	_, err := p.rwc.Write([]byte{GS, 'H', n})
-	return err
+	return p.exec("SelectHRICharacterPrintPosition", []byte{GS, 'H', n})
 }
 
 // [Incomplete] Currently doesn't handle special characters
@@ -57,134 +55,132 @@ func (p *Driver) PrintBarCode(n uint8, m BARCODESYSTEM, d []uint8) error {
 	switch m {
 	case UPCA:
 		if n < 11 || n > 12 {
-			return ErrInvalidBarCodeLength
+			return argError("PrintBarCode", "n", n, "11 <= n <= 12", ErrInvalidBarCodeLength)
 		}
 		if len(d) != int(n) {
-			return ErrBarcodeLengthMismatch
+			return argError("PrintBarCode", "d", len(d), "len(d) == n", ErrBarcodeLengthMismatch)
 		}
 		for _, v := range d {
 			if v < 48 || v > 57 {
-				return ErrInvalidBarCodeChar
+				return argError("PrintBarCode", "d", v, "0-9", ErrInvalidBarCodeChar)
 			}
 		}
 
 	case UPCE:
 		if n < 11 || n > 12 {
-			return ErrInvalidBarCodeLength
+			return argError("PrintBarCode", "n", n, "11 <= n <= 12", ErrInvalidBarCodeLength)
 		}
 		if len(d) != int(n) {
-			return ErrBarcodeLengthMismatch
+			return argError("PrintBarCode", "d", len(d), "len(d) == n", ErrBarcodeLengthMismatch)
 		}
 		for _, v := range d {
 			if v < 48 || v > 57 {
-				return ErrInvalidBarCodeChar
+				return argError("PrintBarCode", "d", v, "0-9", ErrInvalidBarCodeChar)
 			}
 		}
 
 	case EAN13:
 		if n < 12 || n > 13 {
-			return ErrInvalidBarCodeLength
+			return argError("PrintBarCode", "n", n, "12 <= n <= 13", ErrInvalidBarCodeLength)
 		}
 		if len(d) != int(n) {
-			return ErrBarcodeLengthMismatch
+			return argError("PrintBarCode", "d", len(d), "len(d) == n", ErrBarcodeLengthMismatch)
 		}
 		for _, v := range d {
 			if v < 48 || v > 57 {
-				return ErrInvalidBarCodeChar
+				return argError("PrintBarCode", "d", v, "0-9", ErrInvalidBarCodeChar)
 			}
 		}
 
 	case EAN8:
 		if n < 7 || n > 8 {
-			return ErrInvalidBarCodeLength
+			return argError("PrintBarCode", "n", n, "7 <= n <= 8", ErrInvalidBarCodeLength)
 		}
 		if len(d) != int(n) {
-			return ErrBarcodeLengthMismatch
+			return argError("PrintBarCode", "d", len(d), "len(d) == n", ErrBarcodeLengthMismatch)
 		}
 		for _, v := range d {
 			if v < 48 || v > 57 {
-				return ErrInvalidBarCodeChar
+				return argError("PrintBarCode", "d", v, "0-9", ErrInvalidBarCodeChar)
 			}
 		}
 
 	case CODE39:
 		if n < 1 {
-			return ErrInvalidBarCodeLength
+			return argError("PrintBarCode", "n", n, "n >= 1", ErrInvalidBarCodeLength)
 		}
 		if len(d) != int(n) {
-			return ErrBarcodeLengthMismatch
+			return argError("PrintBarCode", "d", len(d), "len(d) == n", ErrBarcodeLengthMismatch)
 		}
 		for _, v := range d {
 			// Find a better way to comply to these conditions
 			// This fucking sucks
 			if (v < 45 && v != 32 && v != 36 && v != 37 && v != 43) || (v > 57 && v != 58 && v < 65) || v > 90 {
-				return ErrInvalidBarCodeChar
+				return argError("PrintBarCode", "d", v, "0-9 A-Z SP $ % + - . /", ErrInvalidBarCodeChar)
 			}
 		}
 
 	case ITF:
 		if n < 1 && (n%2 != 0) {
-			return ErrInvalidBarCodeLength
+			return argError("PrintBarCode", "n", n, "even n >= 2", ErrInvalidBarCodeLength)
 		}
 		if len(d) != int(n) {
-			return ErrBarcodeLengthMismatch
+			return argError("PrintBarCode", "d", len(d), "len(d) == n", ErrBarcodeLengthMismatch)
 		}
 		for _, v := range d {
 			if v < 48 || v > 57 {
-				return ErrInvalidBarCodeChar
+				return argError("PrintBarCode", "d", v, "0-9", ErrInvalidBarCodeChar)
 			}
 		}
 
 	case CODABAR:
 		if n < 1 {
-			return ErrInvalidBarCodeLength
+			return argError("PrintBarCode", "n", n, "n >= 1", ErrInvalidBarCodeLength)
 		}
 		if len(d) != int(n) {
-			return ErrBarcodeLengthMismatch
+			return argError("PrintBarCode", "d", len(d), "len(d) == n", ErrBarcodeLengthMismatch)
 		}
 		for _, v := range d {
 			// Find a better way to comply to these conditions
 			// This fucking sucks
 			if (v < 45 && v != 43 && v != 36) || (v > 57 && v != 58 && v < 68) {
-				return ErrInvalidBarCodeChar
+				return argError("PrintBarCode", "d", v, "0-9 A-D $ + - . / :", ErrInvalidBarCodeChar)
 			}
 		}
 
 	case CODE93:
 		if n < 1 {
-			return ErrInvalidBarCodeLength
+			return argError("PrintBarCode", "n", n, "n >= 1", ErrInvalidBarCodeLength)
 		}
 		if len(d) != int(n) {
-			return ErrBarcodeLengthMismatch
+			return argError("PrintBarCode", "d", len(d), "len(d) == n", ErrBarcodeLengthMismatch)
 		}
 		for _, v := range d {
 			if v > 127 {
-				return ErrInvalidBarCodeChar
+				return argError("PrintBarCode", "d", v, "0 <= d <= 127", ErrInvalidBarCodeChar)
 			}
 		}
 
 	case CODE128:
 		if n < 1 {
-			return ErrInvalidBarCodeLength
+			return argError("PrintBarCode", "n", n, "n >= 1", ErrInvalidBarCodeLength)
 		}
 		if len(d) != int(n) {
-			return ErrBarcodeLengthMismatch
+			return argError("PrintBarCode", "d", len(d), "len(d) == n", ErrBarcodeLengthMismatch)
 		}
 		for _, v := range d {
 			if v > 127 {
-				return ErrInvalidBarCodeChar
+				return argError("PrintBarCode", "d", v, "0 <= d <= 127", ErrInvalidBarCodeChar)
 			}
 		}
 
 	default:
-		return ErrInvalidBarCodeMode
+		return argError("PrintBarCode", "m", m, "UPCA to CODE128", ErrInvalidBarCodeMode)
 	}
 
 	command := []byte{GS, 'k', uint8(m), n}
 	command = append(command, d...)
-	_, err := p.rwc.Write(command)
-
-	return err
+	return p.exec("PrintBarCode", command)
 }
 
 // Sets the horizontal size of the bar code. n
@@ -202,17 +198,15 @@ func (p *Driver) PrintBarCode(n uint8, m BARCODESYSTEM, d []uint8) error {
 // The default value is 3.
 func (p *Driver) SetBarcodeWidth(n uint8) error {
 	if n < 2 || n > 6 {
-		return ErrInvalidBarCodeWidth
+		return argError("SetBarcodeWidth", "n", n, "2 <= n <= 6", ErrInvalidBarCodeWidth)
 	}
-	_, err := p.rwc.Write([]byte{GS, 'w', n})
-	return err
+	return p.exec("SetBarcodeWidth", []byte{GS, 'w', n})
 }
 
 // Sets the printing position of the bar code.
 // The print bar code starting position is: 0->255
 func (p *Driver) SetBarCodePrintPosition(n uint8) error {
-	_, err := p.rwc.Write([]byte{GS, 'x', n})
-	return err
+	return p.exec("SetBarCodePrintPosition", []byte{GS, 'x', n})
 }
 
 // Print 2D barcode
@@ -235,9 +229,7 @@ func (p *Driver) SetBarCodePrintPosition(n uint8) error {
 // dH: Higher number
 // d1..dn: the data to be printed
 func (p *Driver) PrintQRBarcode(m, n, k, dL, dH uint8, d []uint8) error {
-	_, err := p.rwc.Write(append([]byte{ESC, 'Z', m, n, k, dL, dH}, d...))
-
-	return err
+	return p.exec("PrintQRBarcode", append([]byte{ESC, 'Z', m, n, k, dL, dH}, d...))
 }
 
 // Select 2D barcode mode
@@ -246,8 +238,7 @@ func (p *Driver) PrintQRBarcode(m, n, k, dL, dH uint8, d []uint8) error {
 // Default: 0
 func (p *Driver) Select2DBarcodeMode(m uint8) error {
 	if m != 0 && m != 1 {
-		return ErrInvalidBarCodeMode
+		return argError("Select2DBarcodeMode", "m", m, "0 or 1", ErrInvalidBarCodeMode)
 	}
-	_, err := p.rwc.Write([]byte{GS, 'Z', m})
-	return err
+	return p.exec("Select2DBarcodeMode", []byte{GS, 'Z', m})
 }
@@ -0,0 +1,84 @@
+package commands
+
+import "errors"
+
+var (
+	ErrNotConnected = errors.New("driver has no connection")
+)
+
+// Keep commands in memory until Flush is called
+// When chunkSize is positive, full chunks of chunkSize bytes are sent as
+// soon as they are available and Flush sends the rest in chunks of at most
+// chunkSize bytes. Reads flush the buffer first so that status queries
+// reach the printer.
+func WithBuffering(chunkSize int) Option {
+	return func(p *Driver) {
+		p.buffered = true
+		p.chunkSize = chunkSize
+	}
+}
+
+// Initialize a driver that only builds jobs in memory
+// The job is retrieved with Bytes, Flush returns ErrNotConnected.
+func NewBuffer() *Driver {
+	return NewDriver(nil, WithBuffering(0))
+}
+
+// Returns a copy of the commands waiting to be sent
+func (p *Driver) Bytes() []byte {
+	var b []byte
+
+	p.exclusive(func() error {
+		b = append([]byte(nil), p.buf.Bytes()...)
+		return nil
+	})
+
+	return b
+}
+
+// Sends the buffered commands to the printer
+// Data that couldn't be sent stays in the buffer.
+func (p *Driver) Flush() error {
+	return p.exclusive(func() error {
+		return commandError("Flush", p.flush(true))
+	})
+}
+
+// Throws away the buffered commands
+func (p *Driver) Discard() {
+	p.exclusive(func() error {
+		p.buf.Reset()
+		return nil
+	})
+}
+
+// Sends the buffer in chunks, the last partial chunk is only sent when all
+// is set
+func (p *Driver) flush(all bool) error {
+	if p.buf.Len() == 0 {
+		return nil
+	}
+
+	if p.rwc == nil {
+		return ErrNotConnected
+	}
+
+	for p.buf.Len() > 0 {
+		chunk := p.buf.Bytes()
+		if p.chunkSize > 0 && len(chunk) > p.chunkSize {
+			chunk = chunk[:p.chunkSize]
+		}
+
+		if !all && len(chunk) < p.chunkSize {
+			return nil
+		}
+
+		n, err := p.rawWrite(chunk)
+		p.buf.Next(n)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
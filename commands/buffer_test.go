@@ -0,0 +1,194 @@
+package commands_test
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/cyb3rjerry/rongta-escpos/commands"
+	"github.com/cyb3rjerry/rongta-escpos/rongtasim"
+)
+
+// Virtual printer keeping the size of each write
+type chunkConn struct {
+	*rongtasim.Printer
+
+	mu     sync.Mutex
+	writes []int
+}
+
+func (c *chunkConn) Write(b []byte) (int, error) {
+	c.mu.Lock()
+	c.writes = append(c.writes, len(b))
+	c.mu.Unlock()
+
+	return c.Printer.Write(b)
+}
+
+func (c *chunkConn) sizes() []int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append([]int(nil), c.writes...)
+}
+
+func newChunkDriver(t *testing.T, chunkSize int) (*commands.Driver, *chunkConn) {
+	t.Helper()
+
+	conn := &chunkConn{Printer: rongtasim.New()}
+	t.Cleanup(func() { conn.Close() })
+
+	d := commands.NewDriver(conn, commands.WithBuffering(chunkSize))
+
+	return d, conn
+}
+
+func TestBufferSendsFullChunks(t *testing.T) {
+	d, conn := newChunkDriver(t, 4)
+
+	if err := d.WriteStringToBuffer("abc"); err != nil {
+		t.Fatal(err)
+	}
+
+	if sizes := conn.sizes(); len(sizes) != 0 {
+		t.Errorf("wrote %v below the threshold", sizes)
+	}
+
+	// Reaching the threshold sends the chunk, the rest waits for Flush
+	if err := d.WriteStringToBuffer("defghij"); err != nil {
+		t.Fatal(err)
+	}
+
+	if sizes := conn.sizes(); !reflect.DeepEqual(sizes, []int{4, 4}) {
+		t.Errorf("wrote chunks of %v, want [4 4]", sizes)
+	}
+
+	if b := d.Bytes(); string(b) != "ij" {
+		t.Errorf("%q pending, want \"ij\"", b)
+	}
+
+	if err := d.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if sizes := conn.sizes(); !reflect.DeepEqual(sizes, []int{4, 4, 2}) {
+		t.Errorf("wrote chunks of %v, want [4 4 2]", sizes)
+	}
+
+	if w := conn.Raw(); string(w) != "abcdefghij" {
+		t.Errorf("wrote %q, want \"abcdefghij\"", w)
+	}
+
+	if b := d.Bytes(); len(b) != 0 {
+		t.Errorf("%q pending after Flush", b)
+	}
+}
+
+func TestBufferExactChunk(t *testing.T) {
+	d, conn := newChunkDriver(t, 4)
+
+	if err := d.WriteStringToBuffer("abcd"); err != nil {
+		t.Fatal(err)
+	}
+
+	if sizes := conn.sizes(); !reflect.DeepEqual(sizes, []int{4}) {
+		t.Errorf("wrote chunks of %v, want [4]", sizes)
+	}
+
+	if err := d.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if sizes := conn.sizes(); !reflect.DeepEqual(sizes, []int{4}) {
+		t.Errorf("Flush wrote chunks of %v with nothing pending", sizes)
+	}
+}
+
+func TestFlushWritesWholeBuffer(t *testing.T) {
+	d, conn := newChunkDriver(t, 0)
+
+	if err := d.Initialize(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := d.SetJustification(commands.JustifyCenter); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(conn.Raw()) != 0 {
+		t.Fatalf("wrote % x before Flush", conn.Raw())
+	}
+
+	want := d.Bytes()
+	if err := d.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if sizes := conn.sizes(); !reflect.DeepEqual(sizes, []int{len(want)}) {
+		t.Errorf("wrote chunks of %v, want a single write", sizes)
+	}
+
+	if w := conn.Raw(); !bytes.Equal(w, []byte{commands.ESC, '@', commands.ESC, 'a', 1}) {
+		t.Errorf("wrote % x, want ESC @ ESC a 1", w)
+	}
+}
+
+func TestDiscardDropsPendingCommands(t *testing.T) {
+	d, conn := newChunkDriver(t, 0)
+
+	if err := d.SetJustification(commands.JustifyCenter); err != nil {
+		t.Fatal(err)
+	}
+
+	d.Discard()
+
+	if b := d.Bytes(); len(b) != 0 {
+		t.Errorf("%q pending after Discard", b)
+	}
+
+	if err := d.Initialize(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := d.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if w := conn.Raw(); !bytes.Equal(w, []byte{commands.ESC, '@'}) {
+		t.Errorf("wrote % x, want ESC @", w)
+	}
+}
+
+func TestQueryFlushesBuffer(t *testing.T) {
+	d, conn := newChunkDriver(t, 0)
+
+	if err := d.Initialize(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := d.GetAutocutterStatus(); err != nil {
+		t.Fatal(err)
+	}
+
+	if w := conn.Raw(); !bytes.Equal(w, []byte{commands.ESC, '@', commands.DLE, commands.EOT, 3}) {
+		t.Errorf("wrote % x, want ESC @ then the status request", w)
+	}
+}
+
+func TestBufferWithoutConnection(t *testing.T) {
+	d := commands.NewBuffer()
+
+	if err := d.Initialize(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := d.Flush(); !errors.Is(err, commands.ErrNotConnected) {
+		t.Errorf("got %v, want ErrNotConnected", err)
+	}
+
+	if b := d.Bytes(); !bytes.Equal(b, []byte{commands.ESC, '@'}) {
+		t.Errorf("got % x, the job must stay buffered", b)
+	}
+}
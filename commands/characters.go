@@ -84,23 +84,24 @@ var (
 // 8: Japan, 9: Norway, 10: Denmark II, 11: Spain II, 12: Latin America, 13: Korea, 14: Slovenia,
 // 15: China
 func (p *Driver) SelectInternationalCharacterSet(c CharacterSet) error {
-	_, err := p.rwc.Write([]byte{ESC, 'R', byte(c)})
-	return err
+	return p.exec("SelectInternationalCharacterSet", []byte{ESC, 'R', byte(c)})
 }
 
 // Select international character code
 func (p *Driver) SelectInternationalCharacterCode(n CharacterCode) error {
-	_, err := p.rwc.Write([]byte{ESC, 'R', uint8(n)})
-	return err
+	if p.profile != nil && !p.profile.SupportsCodePage(n) {
+		return unsupported("SelectInternationalCharacterCode")
+	}
+
+	return p.exec("SelectInternationalCharacterCode", []byte{ESC, 't', uint8(n)})
 }
 
 // Cancel user-defined characters
 // 32 <= n <= 126
 func (p *Driver) CancelUserDefinedCharacters(n uint8) error {
 	if n < 32 || n > 126 {
-		return ErrInvalidCancelCharacterCode
+		return argError("CancelUserDefinedCharacters", "n", n, "32 <= n <= 126", ErrInvalidCancelCharacterCode)
 	}
 
-	_, err := p.rwc.Write([]byte{ESC, '-', n})
-	return err
+	return p.exec("CancelUserDefinedCharacters", []byte{ESC, '?', n})
 }
@@ -0,0 +1,47 @@
+package commands_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/cyb3rjerry/rongta-escpos/commands"
+)
+
+// The character code table (ESC t) and the international character set
+// (ESC R) are separate settings
+func TestSelectCharacterCodeAndSet(t *testing.T) {
+	got, err := encode(func(d *commands.Driver) error {
+		if err := d.SelectInternationalCharacterCode(commands.CP850); err != nil {
+			return err
+		}
+
+		return d.SelectInternationalCharacterSet(commands.France)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []byte{commands.ESC, 't', 2, commands.ESC, 'R', 1}
+	if !bytes.Equal(got, want) {
+		t.Errorf("sent % x, want % x", got, want)
+	}
+}
+
+func TestCancelUserDefinedCharacters(t *testing.T) {
+	got, err := encode(func(d *commands.Driver) error { return d.CancelUserDefinedCharacters('A') })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []byte{commands.ESC, '?', 'A'}
+	if !bytes.Equal(got, want) {
+		t.Errorf("sent % x, want % x", got, want)
+	}
+
+	for _, n := range []uint8{31, 127} {
+		if _, err := encode(func(d *commands.Driver) error { return d.CancelUserDefinedCharacters(n) }); !errors.Is(err, commands.ErrInvalidCancelCharacterCode) {
+			t.Errorf("CancelUserDefinedCharacters(%d) returned %v, want ErrInvalidCancelCharacterCode", n, err)
+		}
+	}
+}
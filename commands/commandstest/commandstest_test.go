@@ -0,0 +1,110 @@
+package commandstest_test
+
+import (
+	"errors"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cyb3rjerry/rongta-escpos/commands"
+	"github.com/cyb3rjerry/rongta-escpos/commands/commandstest"
+)
+
+// Callers commonly define their own update flag, importing commandstest
+// must not clash with it
+var update = flag.Bool("update", false, "rewrite golden files")
+
+func TestExpectCommand(t *testing.T) {
+	d, conn := commandstest.NewDriver(t)
+
+	if err := d.SetJustification(commands.JustifyCenter); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := d.WriteStringToBuffer("hello"); err != nil {
+		t.Fatal(err)
+	}
+
+	conn.ExpectCommand(t, "SetJustification", commands.JustifyCenter)
+	conn.ExpectCommand(t, "Text", "hello")
+	conn.ExpectNoMoreCommands(t)
+}
+
+func TestReplyIsSentWhenCommandIsWritten(t *testing.T) {
+	d, conn := commandstest.NewDriver(t)
+	conn.Reply("TransmitRealTimeStatus", commands.REALTIME_STATUS_FIXED_BITS|commands.AUTOCUTER_STATUS_MASK)
+
+	jammed, err := d.GetAutocutterStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !jammed {
+		t.Error("autocutter reported working")
+	}
+}
+
+func TestConnRead(t *testing.T) {
+	conn := commandstest.NewConn()
+	buf := make([]byte, 8)
+
+	conn.SetReadDeadline(time.Now().Add(10 * time.Millisecond))
+	if _, err := conn.Read(buf); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("got %v, want os.ErrDeadlineExceeded", err)
+	}
+
+	conn.SetReadDeadline(time.Time{})
+	conn.Inject(1, 2, 3)
+
+	n, err := conn.Read(buf)
+	if err != nil || string(buf[:n]) != "\x01\x02\x03" {
+		t.Fatalf("got % x, %v", buf[:n], err)
+	}
+
+	conn.Close()
+	if _, err := conn.Read(buf); err != io.EOF {
+		t.Errorf("got %v after Close, want io.EOF", err)
+	}
+}
+
+func TestGolden(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	job := commands.NewBuffer()
+	job.SetJustification(commands.JustifyCenter)
+	job.WriteStringToBuffer("total")
+
+	t.Setenv(commandstest.UPDATE_GOLDEN_ENV, "1")
+	commandstest.Golden(t, "receipt", job.Bytes())
+
+	b, err := os.ReadFile(filepath.Join(dir, "testdata", "receipt.golden"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := commandstest.Disassemble(job.Bytes()); string(b) != want {
+		t.Errorf("golden file holds %q, want %q", b, want)
+	}
+
+	if !strings.Contains(string(b), "SetJustification") {
+		t.Errorf("golden file %q misses the justification", b)
+	}
+
+	t.Setenv(commandstest.UPDATE_GOLDEN_ENV, "")
+	if !commandstest.Golden(t, "receipt", job.Bytes()) {
+		t.Error("job differs from the golden file it just wrote")
+	}
+}
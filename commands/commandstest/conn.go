@@ -0,0 +1,147 @@
+// Package commandstest provides a fake printer connection and assertions
+// for testing code built on commands.Driver.
+//
+// The assertions work on decoded commands rather than raw bytes:
+//
+//	d, conn := commandstest.NewDriver(t)
+//	d.SetJustification(commands.JustifyCenter)
+//	conn.ExpectCommand(t, "SetJustification", commands.JustifyCenter)
+package commandstest
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cyb3rjerry/rongta-escpos/commands"
+	"github.com/cyb3rjerry/rongta-escpos/internal/replies"
+)
+
+// A fake printer connection
+// Writes are recorded, and replies scripted with Reply are sent back when
+// their command is written. Reads block until a reply is available, the
+// read deadline expires or the connection is closed.
+type Conn struct {
+	mu sync.Mutex
+
+	written []byte
+	pending []byte              // Incomplete command waiting for more bytes
+	replies map[string][][]byte // Scripted replies by command name
+	next    int                 // Commands already checked by ExpectCommand
+
+	out    *replies.Queue
+	closed bool
+}
+
+func NewConn() *Conn {
+	return &Conn{
+		replies: map[string][][]byte{},
+		out:     replies.NewQueue(),
+	}
+}
+
+// Initialize a driver on a fake connection, closed when the test ends
+func NewDriver(t testing.TB, opts ...commands.Option) (*commands.Driver, *Conn) {
+	conn := NewConn()
+	d := commands.NewDriver(conn, opts...)
+	t.Cleanup(func() {
+		d.Close()
+	})
+
+	return d, conn
+}
+
+// Queues a reply sent the next time command name is written
+// Replies to the same command are sent in the order they were queued.
+func (c *Conn) Reply(name string, reply ...byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.replies[name] = append(c.replies[name], reply)
+}
+
+// Sends unsolicited bytes, like ASB frames, to the host
+func (c *Conn) Inject(b ...byte) {
+	c.out.Push(b...)
+}
+
+// Returns a copy of every byte written
+func (c *Conn) Written() []byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return bytes.Clone(c.written)
+}
+
+// Returns every command written
+func (c *Conn) Commands() []commands.Command {
+	cmds, _ := commands.Decode(c.Written())
+	return cmds
+}
+
+// Forgets the bytes written so far
+func (c *Conn) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.written = nil
+	c.pending = nil
+	c.next = 0
+}
+
+func (c *Conn) Write(b []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return 0, os.ErrClosed
+	}
+
+	c.written = append(c.written, b...)
+	c.pending = append(c.pending, b...)
+
+	for len(c.pending) > 0 {
+		cmd, n, err := commands.DecodeNext(c.pending)
+		if errors.Is(err, commands.ErrTruncatedCommand) {
+			break
+		}
+		c.pending = c.pending[n:]
+
+		if queue := c.replies[cmd.Name]; len(queue) > 0 {
+			c.replies[cmd.Name] = queue[1:]
+			c.out.Push(queue[0]...)
+		}
+	}
+
+	return len(b), nil
+}
+
+func (c *Conn) Read(b []byte) (int, error) {
+	return c.out.Read(b)
+}
+
+func (c *Conn) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return os.ErrClosed
+	}
+
+	c.closed = true
+	c.out.Close()
+
+	return nil
+}
+
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	return c.out.SetReadDeadline(t)
+}
+
+// Writes never block
+func (c *Conn) SetWriteDeadline(t time.Time) error {
+	return nil
+}
@@ -0,0 +1,94 @@
+package commandstest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/cyb3rjerry/rongta-escpos/commands"
+)
+
+// Checks that the next command written is name with the given arguments
+// Each call moves on to the following command. Arguments are compared by
+// their printed form, so untyped constants match the decoded uint8 values.
+// Only the given arguments are checked, and a trailing []byte argument is
+// compared with the command payload.
+func (c *Conn) ExpectCommand(t testing.TB, name string, args ...any) bool {
+	t.Helper()
+
+	cmds := c.Commands()
+
+	c.mu.Lock()
+	next := c.next
+	if next < len(cmds) {
+		c.next++
+	}
+	c.mu.Unlock()
+
+	if next >= len(cmds) {
+		t.Errorf("expected %s, no more commands were written", name)
+		return false
+	}
+
+	cmd := cmds[next]
+	if err := match(cmd, name, args); err != nil {
+		t.Errorf("command %d at offset %d: expected %s, got %s: %v", next, cmd.Offset, name, cmd, err)
+		return false
+	}
+
+	return true
+}
+
+// Checks that every command written was checked by ExpectCommand
+func (c *Conn) ExpectNoMoreCommands(t testing.TB) bool {
+	t.Helper()
+
+	cmds := c.Commands()
+
+	c.mu.Lock()
+	next := c.next
+	c.mu.Unlock()
+
+	if next < len(cmds) {
+		t.Errorf("%d unexpected commands, starting with %s", len(cmds)-next, cmds[next])
+		return false
+	}
+
+	return true
+}
+
+func match(cmd commands.Command, name string, args []any) error {
+	if cmd.Name != name {
+		return errors.New("wrong command")
+	}
+
+	if len(args) > 0 {
+		if data, ok := args[len(args)-1].([]byte); ok {
+			if !bytes.Equal(data, cmd.Data) {
+				return fmt.Errorf("payload is % x", cmd.Data)
+			}
+			args = args[:len(args)-1]
+		}
+	}
+
+	if len(args) > len(cmd.Args) {
+		return fmt.Errorf("%d arguments given, the command has %d", len(args), len(cmd.Args))
+	}
+
+	for i, arg := range args {
+		if format(arg) != format(cmd.Args[i]) {
+			return fmt.Errorf("argument %d is %s", i, format(cmd.Args[i]))
+		}
+	}
+
+	return nil
+}
+
+func format(arg any) string {
+	if pm, ok := arg.(*commands.PrintMode); ok {
+		return fmt.Sprintf("%+v", *pm)
+	}
+
+	return fmt.Sprint(arg)
+}
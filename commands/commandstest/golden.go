@@ -0,0 +1,96 @@
+package commandstest
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cyb3rjerry/rongta-escpos/commands"
+)
+
+// Set to rewrite the golden files instead of comparing them
+const UPDATE_GOLDEN_ENV = "UPDATE_GOLDEN"
+
+// Compares a whole job against the golden file testdata/<name>.golden
+// The file holds the job's disassembly, one command per line, so that
+// differences are readable. Run the tests with UPDATE_GOLDEN=1 to rewrite
+// it.
+func Golden(t testing.TB, name string, job []byte) bool {
+	t.Helper()
+
+	got := Disassemble(job)
+	path := filepath.Join("testdata", name+".golden")
+
+	if updating() {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		return true
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Errorf("%v, run the test with %s=1 to create it", err, UPDATE_GOLDEN_ENV)
+		return false
+	}
+
+	if got != string(want) {
+		t.Errorf("job differs from %s:\n%s", path, diff(string(want), got))
+		return false
+	}
+
+	return true
+}
+
+// Returns the commands of job, one per line
+// A decoding error is reported on the last line.
+func Disassemble(job []byte) string {
+	var b strings.Builder
+
+	cmds, err := commands.Decode(job)
+	for _, cmd := range cmds {
+		fmt.Fprintln(&b, cmd)
+	}
+
+	if err != nil {
+		fmt.Fprintln(&b, "error:", err)
+	}
+
+	return b.String()
+}
+
+func updating() bool {
+	v := os.Getenv(UPDATE_GOLDEN_ENV)
+	return v != "" && v != "0" && v != "false"
+}
+
+// Lists the lines of want and got from the first difference
+func diff(want, got string) string {
+	wantLines := strings.Split(want, "\n")
+	gotLines := strings.Split(got, "\n")
+
+	first := 0
+	for first < len(wantLines) && first < len(gotLines) && wantLines[first] == gotLines[first] {
+		first++
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "first difference at line %d\n", first+1)
+
+	for i := first; i < len(wantLines) && i < first+5; i++ {
+		fmt.Fprintf(&b, "- %s\n", wantLines[i])
+	}
+
+	for i := first; i < len(gotLines) && i < first+5; i++ {
+		fmt.Fprintf(&b, "+ %s\n", gotLines[i])
+	}
+
+	return b.String()
+}
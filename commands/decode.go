@@ -0,0 +1,560 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	ErrUnknownCommand   = errors.New("unknown command")
+	ErrTruncatedCommand = errors.New("truncated command")
+)
+
+// A command decoded from a byte stream
+// Name is the Driver method emitting the command, "Text" for printable
+// text (including HT, LF and CR) and "Unknown" for unrecognised bytes.
+// Requests sent by the status getters are named after the query, as in
+// "TransmitRealTimeStatus". Methods sending another method's command, such
+// as SetASB or the Length variants, decode to that method. Commands the
+// driver doesn't send keep the manual's function name: "CutPaper" (GS V),
+// "CancelPrintData" (CAN), "SelectKanjiMode" (FS &), "CancelKanjiMode"
+// (FS .) and "RealTimeRequest" (DLE ENQ).
+// Args hold the method parameters with their Go types, except for images
+// whose dimensions are combined into a Size. Trailing payloads (bar code,
+// image and macro data) are kept in Data.
+type Command struct {
+	Name   string
+	Args   []any
+	Data   []byte
+	Raw    []byte
+	Offset int // Position of the command in the decoded stream
+}
+
+// Image dimensions in dots
+type Size struct {
+	Width  int
+	Height int
+}
+
+func (s Size) String() string {
+	return fmt.Sprintf("%dx%d", s.Width, s.Height)
+}
+
+var barcodeSystemNames = map[BARCODESYSTEM]string{
+	UPCA:    "UPCA",
+	UPCE:    "UPCE",
+	EAN13:   "EAN13",
+	EAN8:    "EAN8",
+	CODE39:  "CODE39",
+	ITF:     "ITF",
+	CODABAR: "CODABAR",
+	CODE93:  "CODE93",
+	CODE128: "CODE128",
+}
+
+func (f Font) String() string {
+	if f == FontB {
+		return "B"
+	}
+	return "A"
+}
+
+func (u Underline) String() string {
+	switch u {
+	case UnderlineNone:
+		return "None"
+	case UnderlineThin:
+		return "Thin"
+	case UnderlineThick:
+		return "Thick"
+	}
+	return fmt.Sprintf("Underline(%d)", uint8(u))
+}
+
+func (j Justify) String() string {
+	switch j {
+	case JustifyLeft:
+		return "Left"
+	case JustifyCenter:
+		return "Center"
+	case JustifyRight:
+		return "Right"
+	}
+	return fmt.Sprintf("Justify(%d)", uint8(j))
+}
+
+func (m BARCODESYSTEM) String() string {
+	if name, ok := barcodeSystemNames[m]; ok {
+		return name
+	}
+	return fmt.Sprintf("BARCODESYSTEM(%d)", uint8(m))
+}
+
+// Locates a decoding problem in the stream
+type DecodeError struct {
+	Offset int
+	Err    error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("offset %d: %v", e.Offset, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+func (c Command) String() string {
+	if c.Name == "Unknown" {
+		return fmt.Sprintf("Unknown{% x}", c.Raw)
+	}
+
+	var b strings.Builder
+	b.WriteString(c.Name)
+	b.WriteByte('{')
+
+	for i, arg := range c.Args {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+
+		switch v := arg.(type) {
+		case string:
+			fmt.Fprintf(&b, "%q", v)
+		case *PrintMode:
+			fmt.Fprintf(&b, "%+v", *v)
+		default:
+			fmt.Fprint(&b, v)
+		}
+	}
+
+	if len(c.Data) > 0 {
+		if len(c.Args) > 0 {
+			b.WriteString(", ")
+		}
+		fmt.Fprintf(&b, "%d bytes", len(c.Data))
+	}
+
+	b.WriteByte('}')
+
+	return b.String()
+}
+
+// Turns a raw stream back into commands
+// Unknown sequences are returned as "Unknown" commands and decoding goes
+// on, a truncated command at the end of the stream stops it. The error
+// reports the first problem as a *DecodeError.
+func Decode(b []byte) ([]Command, error) {
+	var (
+		cmds     []Command
+		firstErr error
+	)
+
+	for offset := 0; offset < len(b); {
+		cmd, n, err := DecodeNext(b[offset:])
+		if errors.Is(err, ErrTruncatedCommand) {
+			if firstErr == nil {
+				firstErr = &DecodeError{Offset: offset, Err: err}
+			}
+			break
+		}
+
+		cmd.Offset = offset
+		cmds = append(cmds, cmd)
+
+		if err != nil && firstErr == nil {
+			firstErr = &DecodeError{Offset: offset, Err: err}
+		}
+
+		offset += n
+	}
+
+	return cmds, firstErr
+}
+
+// Decodes the command at the start of b
+// Returns the number of bytes used. Unknown sequences are returned as an
+// "Unknown" command along with ErrUnknownCommand, and ErrTruncatedCommand
+// means more bytes are needed.
+func DecodeNext(b []byte) (Command, int, error) {
+	if len(b) == 0 {
+		return Command{}, 0, ErrTruncatedCommand
+	}
+
+	if isText(b[0]) {
+		n := 1
+		for n < len(b) && isText(b[n]) {
+			n++
+		}
+
+		return Command{Name: "Text", Args: []any{string(b[:n])}, Raw: b[:n]}, n, nil
+	}
+
+	switch b[0] {
+	case FF:
+		return Command{Name: "PrintBufferAndReturnToStandardMode", Raw: b[:1]}, 1, nil
+	case CAN:
+		return Command{Name: "CancelPrintData", Raw: b[:1]}, 1, nil
+	case ESC, GS, DLE, FS, DC2:
+	default:
+		return unknown(b, 1)
+	}
+
+	if len(b) < 2 {
+		return Command{}, 0, ErrTruncatedCommand
+	}
+
+	var dec decoder
+	switch b[0] {
+	case ESC:
+		dec = escCommands[b[1]]
+	case GS:
+		dec = gsCommands[b[1]]
+	case DLE:
+		dec = dleCommands[b[1]]
+	case FS:
+		dec = fsCommands[b[1]]
+	case DC2:
+		dec = dc2Commands[b[1]]
+	}
+
+	if dec == nil {
+		return unknown(b, 2)
+	}
+
+	cmd, n, err := dec(b)
+	if err != nil {
+		return cmd, n, err
+	}
+
+	cmd.Raw = b[:n]
+
+	return cmd, n, nil
+}
+
+func isText(c byte) bool {
+	return c >= SP || c == HT || c == LF || c == CR
+}
+
+func unknown(b []byte, n int) (Command, int, error) {
+	return Command{Name: "Unknown", Raw: b[:n]}, n, ErrUnknownCommand
+}
+
+// Decodes a command whose 2 byte prefix is known
+type decoder func(b []byte) (Command, int, error)
+
+// Converts a parameter byte to the type the Driver method takes
+type arg func(b byte) any
+
+func u8(b byte) any           { return b }
+func lsb(b byte) any          { return b&0x01 != 0 }
+func font(b byte) any         { return Font(b&0x01 != 0) }
+func justify(b byte) any      { return Justify(b) }
+func underline(b byte) any    { return Underline(b) }
+func characterSet(b byte) any { return CharacterSet(b) }
+func characterCode(b byte) any {
+	return CharacterCode(b)
+}
+
+// Command with a fixed number of parameters after a prefix of prefixLen bytes
+func fixed(name string, prefixLen int, args ...arg) decoder {
+	return func(b []byte) (Command, int, error) {
+		n := prefixLen + len(args)
+		if len(b) < n {
+			return Command{}, 0, ErrTruncatedCommand
+		}
+
+		cmd := Command{Name: name}
+		for i, a := range args {
+			cmd.Args = append(cmd.Args, a(b[prefixLen+i]))
+		}
+
+		return cmd, n, nil
+	}
+}
+
+// Commands selected by their third byte, like GS C 0 / GS C 1
+func sub(commands map[byte]decoder) decoder {
+	return func(b []byte) (Command, int, error) {
+		if len(b) < 3 {
+			return Command{}, 0, ErrTruncatedCommand
+		}
+
+		dec := commands[b[2]]
+		if dec == nil {
+			return unknown(b, 3)
+		}
+
+		return dec(b)
+	}
+}
+
+// Command with a payload of size bytes after n header bytes
+// size reads the header, which is known to be complete
+func payload(b []byte, n int, size func(h []byte) int) (int, []byte, error) {
+	if len(b) < n {
+		return 0, nil, ErrTruncatedCommand
+	}
+
+	total := n + size(b[:n])
+	if len(b) < total {
+		return 0, nil, ErrTruncatedCommand
+	}
+
+	return total, b[n:total], nil
+}
+
+func word(l, h byte) int {
+	return int(l) + int(h)*256
+}
+
+var escCommands = map[byte]decoder{
+	ENQ: sub(map[byte]decoder{
+		0x01: fixed("RecoverAndRestartPrint", 3),
+		0x02: fixed("RecoverAndCancelPrint", 3),
+	}),
+	SP: fixed("SetRightSideChar", 2, u8),
+	BANG: func(b []byte) (Command, int, error) {
+		if len(b) < 3 {
+			return Command{}, 0, ErrTruncatedCommand
+		}
+
+		return Command{Name: "SetPrintMode", Args: []any{&PrintMode{
+			Font:           Font(b[2]&0x01 != 0),
+			IsEmphasized:   b[2]&0x08 != 0,
+			IsDoubleHeight: b[2]&0x10 != 0,
+			IsDoubleWidth:  b[2]&0x20 != 0,
+			IsUnderline:    b[2]&0x80 != 0,
+		}}}, 3, nil
+	},
+	DOLLAR:  fixed("SetAbsolutePrintPosition", 2, u8, u8),
+	PERCENT: fixed("SelectUserDefinedCharacter", 2, u8),
+	AMPERSAND: func(b []byte) (Command, int, error) {
+		// ESC & y c1 c2 [x d1...d(y*x)]k
+		if len(b) < 5 {
+			return Command{}, 0, ErrTruncatedCommand
+		}
+
+		n := 5
+		for c := int(b[3]); c <= int(b[4]); c++ {
+			if len(b) <= n {
+				return Command{}, 0, ErrTruncatedCommand
+			}
+			n += 1 + int(b[2])*int(b[n])
+		}
+
+		if len(b) < n {
+			return Command{}, 0, ErrTruncatedCommand
+		}
+
+		return Command{Name: "DefineUserDefinedCharacters", Args: []any{b[2], b[3], b[4]}, Data: b[5:n]}, n, nil
+	},
+	ASTERISK: func(b []byte) (Command, int, error) {
+		// ESC * m nL nH d1...dk
+		n, data, err := payload(b, 5, func(h []byte) int {
+			if h[2] == 32 || h[2] == 33 {
+				return word(h[3], h[4]) * 3
+			}
+			return word(h[3], h[4])
+		})
+		if err != nil {
+			return Command{}, 0, err
+		}
+
+		height := 8
+		if b[2] == 32 || b[2] == 33 {
+			height = 24
+		}
+
+		return Command{Name: "SelectBitImageMode", Args: []any{b[2], Size{word(b[3], b[4]), height}}, Data: data}, n, nil
+	},
+	DASH: fixed("SetUnderline", 2, underline),
+	'2':  fixed("SetDefaultLineSpacing", 2),
+	'3':  fixed("SetLineSpacing", 2, u8),
+	'=':  fixed("SetPeripheralDevice", 2, u8),
+	'?':  fixed("CancelUserDefinedCharacters", 2, u8),
+	'@':  fixed("Initialize", 2),
+	'B':  fixed("SetBeepPrompt", 2, u8, u8),
+	'D':  fixed("SetHorizontalTabPositions", 2, u8, u8),
+	'E':  fixed("SetEmphasizedMode", 2, u8),
+	'G':  fixed("SetDoubleStrikeMode", 2, u8),
+	'J':  fixed("PrintAndFeedNDotsLines", 2, u8),
+	'L':  fixed("SelectPageMode", 2),
+	'M':  fixed("SetCharacterFont", 2, font),
+	'R':  fixed("SelectInternationalCharacterSet", 2, characterSet),
+	'S':  fixed("SelectStandardMode", 2),
+	'T':  fixed("SelectPrintDirectionInPageMode", 2, u8),
+	'V':  fixed("RotateClockwise90Degrees", 2, lsb),
+	'W':  fixed("SetPrintAreaInPageMode", 2, u8, u8, u8, u8, u8, u8, u8, u8),
+	'Z': func(b []byte) (Command, int, error) {
+		// ESC Z m n k dL dH d1...dn
+		n, data, err := payload(b, 7, func(h []byte) int {
+			return word(h[5], h[6])
+		})
+		if err != nil {
+			return Command{}, 0, err
+		}
+
+		return Command{Name: "PrintQRBarcode", Args: []any{b[2], b[3], b[4], b[5], b[6]}, Data: data}, n, nil
+	},
+	BACKSLASH: fixed("SetRelativePrintPosition", 2, u8, u8),
+	'a':       fixed("SetJustification", 2, justify),
+	'c': sub(map[byte]decoder{
+		'5': fixed("DisablePanelButtons", 3, u8),
+	}),
+	'd': fixed("PrintAndFeedNLines", 2, u8),
+	'i': fixed("Cut", 2),
+	'p': fixed("GeneratePulse", 2, func(b byte) any { return b == 0x05 }, u8, u8),
+	't': fixed("SelectInternationalCharacterCode", 2, characterCode),
+	FF:  fixed("PrintBufferInPageMode", 2),
+}
+
+var gsCommands = map[byte]decoder{
+	BANG: func(b []byte) (Command, int, error) {
+		if len(b) < 3 {
+			return Command{}, 0, ErrTruncatedCommand
+		}
+
+		return Command{Name: "SelectCharacterSize", Args: []any{b[2]>>4 + 1, b[2]&0x07 + 1}}, 3, nil
+	},
+	DOLLAR: fixed("SetAbsoluteVerticalPrintPositionInPageMode", 2, u8, u8),
+	ASTERISK: func(b []byte) (Command, int, error) {
+		// GS * x y d1...d(x*y*8)
+		n, data, err := payload(b, 4, func(h []byte) int {
+			return int(h[2]) * int(h[3]) * 8
+		})
+		if err != nil {
+			return Command{}, 0, err
+		}
+
+		return Command{Name: "DefineDownloadedBitImage", Args: []any{Size{int(b[2]) * 8, int(b[3]) * 8}}, Data: data}, n, nil
+	},
+	'(':   fixed("ExecuteTestPrint", 2, u8, u8, u8, u8),
+	SLASH: fixed("PrintDownloadedBitImage", 2, u8),
+	':':   fixed("ToggleMacroDefinition", 2),
+	'B':   fixed("SetWhiteBlackReversePrintingMode", 2, u8),
+	'C': sub(map[byte]decoder{
+		'0': fixed("SelectCounterPrintMode", 3, u8),
+		'1': fixed("SelectCountMode", 3, u8, u8, u8, u8, u8, u8),
+		'2': fixed("SetCounterValue", 3, u8, u8),
+	}),
+	'c': sub(map[byte]decoder{
+		'3': fixed("PrintCounter", 3),
+	}),
+	FF:  fixed("FeedMarkedPaper", 2),
+	'H': fixed("SelectHRICharacterPrintPosition", 2, u8),
+	'I': fixed("TransmitPrinterID", 2, func(b byte) any { return PrinterIDInfo(b) }),
+	'L': fixed("SetLeftMargin", 2, u8, u8),
+	'P': fixed("SetMotionUnits", 2, u8, u8),
+	'V': func(b []byte) (Command, int, error) {
+		if len(b) < 3 {
+			return Command{}, 0, ErrTruncatedCommand
+		}
+
+		switch b[2] {
+		case 0, 1, 48, 49:
+			return Command{Name: "CutPaper", Args: []any{b[2]}}, 3, nil
+		case 0x66:
+			return fixed("SelectCutModeAndCutPaper", 3, u8)(b)
+		}
+
+		return fixed("CutPaper", 2, u8, u8)(b)
+	},
+	'W':       fixed("SetPrintingAreaWidth", 2, u8, u8),
+	'Z':       fixed("Select2DBarcodeMode", 2, u8),
+	BACKSLASH: fixed("SetRelativeVerticalPrintPositionInPageMode", 2, u8, u8),
+	'^':       fixed("ExecuteMacro", 2, u8, u8, u8),
+	'a':       fixed("ToggleASB", 2, u8),
+	'f':       fixed("SelectFontForHRICharacters", 2, u8),
+	'k': func(b []byte) (Command, int, error) {
+		if len(b) < 4 {
+			return Command{}, 0, ErrTruncatedCommand
+		}
+
+		// GS k m d1...dk NUL
+		if b[2] < byte(UPCA) {
+			for i := 3; i < len(b); i++ {
+				if b[i] == NUL {
+					return Command{Name: "PrintBarCode", Args: []any{uint8(i - 3), BARCODESYSTEM(b[2])}, Data: b[3:i]}, i + 1, nil
+				}
+			}
+
+			return Command{}, 0, ErrTruncatedCommand
+		}
+
+		// GS k m n d1...dn
+		n, data, err := payload(b, 4, func(h []byte) int {
+			return int(h[3])
+		})
+		if err != nil {
+			return Command{}, 0, err
+		}
+
+		return Command{Name: "PrintBarCode", Args: []any{b[3], BARCODESYSTEM(b[2])}, Data: data}, n, nil
+	},
+	'p': fixed("PrintNVBitImageMode", 2, u8, u8),
+	'r': fixed("TransmitStatus", 2, u8),
+	'v': sub(map[byte]decoder{
+		'0': func(b []byte) (Command, int, error) {
+			// GS v 0 m xL xH yL yH d1...dk
+			n, data, err := payload(b, 8, func(h []byte) int {
+				return word(h[4], h[5]) * word(h[6], h[7])
+			})
+			if err != nil {
+				return Command{}, 0, err
+			}
+
+			return Command{Name: "PrintRasterBitImage", Args: []any{b[3], Size{word(b[4], b[5]) * 8, word(b[6], b[7])}}, Data: data}, n, nil
+		},
+	}),
+	'w': fixed("SetBarcodeWidth", 2, u8),
+	'x': fixed("SetBarCodePrintPosition", 2, u8),
+}
+
+var dleCommands = map[byte]decoder{
+	EOT: fixed("TransmitRealTimeStatus", 2, u8),
+	ENQ: fixed("RealTimeRequest", 2, u8),
+	DC4: func(b []byte) (Command, int, error) {
+		// DLE DC4 1 m t
+		if len(b) < 5 {
+			return Command{}, 0, ErrTruncatedCommand
+		}
+
+		if b[2] != 0x01 {
+			return unknown(b, 3)
+		}
+
+		return Command{Name: "SendPulseToPin", Args: []any{b[3] == 0x01, b[4]}}, 5, nil
+	},
+}
+
+var fsCommands = map[byte]decoder{
+	'p': fixed("PrintNVBitImage", 2, u8, u8),
+	'q': func(b []byte) (Command, int, error) {
+		// FS q n [xL xH yL yH d1...dk]1...[...]n
+		if len(b) < 3 {
+			return Command{}, 0, ErrTruncatedCommand
+		}
+
+		n := 3
+		for image := 0; image < int(b[2]); image++ {
+			if len(b) < n+4 {
+				return Command{}, 0, ErrTruncatedCommand
+			}
+			n += 4 + word(b[n], b[n+1])*word(b[n+2], b[n+3])*8
+		}
+
+		if len(b) < n {
+			return Command{}, 0, ErrTruncatedCommand
+		}
+
+		return Command{Name: "DefineNVBitImage", Args: []any{b[2]}, Data: b[3:n]}, n, nil
+	},
+	AMPERSAND: fixed("SelectKanjiMode", 2),
+	'.':       fixed("CancelKanjiMode", 2),
+}
+
+var dc2Commands = map[byte]decoder{
+	'T': fixed("PrintTestPage", 2),
+}
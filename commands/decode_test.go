@@ -0,0 +1,180 @@
+package commands_test
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/cyb3rjerry/rongta-escpos/commands"
+)
+
+type u8 = uint8
+
+// Every command the driver sends decodes back to the method emitting it,
+// with its parameters
+func TestDecodeDriverCommands(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		call func(d *commands.Driver) error
+		want string // Decoded name when it isn't the method name
+		args []any
+		data []byte
+	}{
+		{"SelectFontForHRICharacters", func(d *commands.Driver) error { return d.SelectFontForHRICharacters(1) }, "", []any{u8(1)}, nil},
+		{"SelectHRICharacterPrintPosition", func(d *commands.Driver) error { return d.SelectHRICharacterPrintPosition(2) }, "", []any{u8(2)}, nil},
+		{"PrintBarCode", func(d *commands.Driver) error { return d.PrintBarCode(4, commands.CODE39, []byte("1234")) }, "", []any{u8(4), commands.CODE39}, []byte("1234")},
+		{"SetBarcodeWidth", func(d *commands.Driver) error { return d.SetBarcodeWidth(3) }, "", []any{u8(3)}, nil},
+		{"SetBarCodePrintPosition", func(d *commands.Driver) error { return d.SetBarCodePrintPosition(1) }, "", []any{u8(1)}, nil},
+		{"PrintQRBarcode", func(d *commands.Driver) error { return d.PrintQRBarcode(0, 'M', 4, 2, 0, []byte("hi")) }, "", []any{u8(0), u8('M'), u8(4), u8(2), u8(0)}, []byte("hi")},
+		{"Select2DBarcodeMode", func(d *commands.Driver) error { return d.Select2DBarcodeMode(1) }, "", []any{u8(1)}, nil},
+		{"SelectInternationalCharacterSet", func(d *commands.Driver) error { return d.SelectInternationalCharacterSet(3) }, "", []any{commands.CharacterSet(3)}, nil},
+		{"SelectInternationalCharacterCode", func(d *commands.Driver) error { return d.SelectInternationalCharacterCode(commands.CP850) }, "", []any{commands.CP850}, nil},
+		{"CancelUserDefinedCharacters", func(d *commands.Driver) error { return d.CancelUserDefinedCharacters(65) }, "", []any{u8(65)}, nil},
+		{"WriteStringToBuffer", func(d *commands.Driver) error { return d.WriteStringToBuffer("hello\n") }, "Text", []any{"hello\n"}, nil},
+		{"SetRightSideChar", func(d *commands.Driver) error { return d.SetRightSideChar(2) }, "", []any{u8(2)}, nil},
+		{"SetPrintMode", func(d *commands.Driver) error { return d.SetPrintMode(&commands.PrintMode{IsEmphasized: true}) }, "", []any{&commands.PrintMode{IsEmphasized: true}}, nil},
+		{"SetAbsolutePrintPosition", func(d *commands.Driver) error { return d.SetAbsolutePrintPosition(10, 1) }, "", []any{u8(10), u8(1)}, nil},
+		{"SelectUserDefinedCharacter", func(d *commands.Driver) error { return d.SelectUserDefinedCharacter(1) }, "", []any{u8(1)}, nil},
+		{"SetUnderline", func(d *commands.Driver) error { return d.SetUnderline(commands.UnderlineThin) }, "", []any{commands.UnderlineThin}, nil},
+		{"SetDefaultLineSpacing", func(d *commands.Driver) error { return d.SetDefaultLineSpacing() }, "", nil, nil},
+		{"SetLineSpacing", func(d *commands.Driver) error { return d.SetLineSpacing(30) }, "", []any{u8(30)}, nil},
+		{"Initialize", func(d *commands.Driver) error { return d.Initialize() }, "", nil, nil},
+		{"SetHorizontalTabPositions", func(d *commands.Driver) error { return d.SetHorizontalTabPositions(8, 1) }, "", []any{u8(8), u8(1)}, nil},
+		{"SetEmphasizedMode", func(d *commands.Driver) error { return d.SetEmphasizedMode(1) }, "", []any{u8(1)}, nil},
+		{"SetDoubleStrikeMode", func(d *commands.Driver) error { return d.SetDoubleStrikeMode(1) }, "", []any{u8(1)}, nil},
+		{"PrintAndFeedNLines", func(d *commands.Driver) error { return d.PrintAndFeedNLines(2) }, "", []any{u8(2)}, nil},
+		{"SetCharacterFont", func(d *commands.Driver) error { return d.SetCharacterFont(commands.FontB) }, "", []any{commands.FontB}, nil},
+		{"RotateClockwise90Degrees", func(d *commands.Driver) error { return d.RotateClockwise90Degrees(true) }, "", []any{true}, nil},
+		{"SetRelativePrintPosition", func(d *commands.Driver) error { return d.SetRelativePrintPosition(10, 0) }, "", []any{u8(10), u8(0)}, nil},
+		{"SetJustification", func(d *commands.Driver) error { return d.SetJustification(commands.JustifyCenter) }, "", []any{commands.JustifyCenter}, nil},
+		{"PrintAndFeedNDotsLines", func(d *commands.Driver) error { return d.PrintAndFeedNDotsLines(20) }, "", []any{u8(20)}, nil},
+		{"SelectCharacterSize", func(d *commands.Driver) error { return d.SelectCharacterSize(2, 3) }, "", []any{u8(2), u8(3)}, nil},
+		{"SetWhiteBlackReversePrintingMode", func(d *commands.Driver) error { return d.SetWhiteBlackReversePrintingMode(1) }, "", []any{u8(1)}, nil},
+		{"SetLeftMargin", func(d *commands.Driver) error { return d.SetLeftMargin(10, 0) }, "", []any{u8(10), u8(0)}, nil},
+		{"SelectCutModeAndCutPaper", func(d *commands.Driver) error { return d.SelectCutModeAndCutPaper(3) }, "", []any{u8(3)}, nil},
+		{"SetPrintingAreaWidth", func(d *commands.Driver) error { return d.SetPrintingAreaWidth(0x80, 1) }, "", []any{u8(0x80), u8(1)}, nil},
+		{"PrintBufferAndReturnToStandardMode", func(d *commands.Driver) error { return d.PrintBufferAndReturnToStandardMode() }, "", nil, nil},
+		{"PrintBufferInPageMode", func(d *commands.Driver) error { return d.PrintBufferInPageMode() }, "", nil, nil},
+		{"SelectPageMode", func(d *commands.Driver) error { return d.SelectPageMode() }, "", nil, nil},
+		{"SelectStandardMode", func(d *commands.Driver) error { return d.SelectStandardMode() }, "", nil, nil},
+		{"SelectPrintDirectionInPageMode", func(d *commands.Driver) error { return d.SelectPrintDirectionInPageMode(1) }, "", []any{u8(1)}, nil},
+		{"SetPrintAreaInPageMode", func(d *commands.Driver) error { return d.SetPrintAreaInPageMode(1, 0, 2, 0, 3, 2, 4, 2) }, "", []any{u8(1), u8(0), u8(2), u8(0), u8(3), u8(2), u8(4), u8(2)}, nil},
+		{"SetAbsoluteVerticalPrintPositionInPageMode", func(d *commands.Driver) error { return d.SetAbsoluteVerticalPrintPositionInPageMode(10, 0) }, "", []any{u8(10), u8(0)}, nil},
+		{"SetRelativeVerticalPrintPositionInPageMode", func(d *commands.Driver) error { return d.SetRelativeVerticalPrintPositionInPageMode(10, 0) }, "", []any{u8(10), u8(0)}, nil},
+		{"SelectBitImageMode", func(d *commands.Driver) error { return d.SelectBitImageMode(0, 2, 0, []byte{0xFF, 0x00}) }, "", []any{u8(0), commands.Size{Width: 2, Height: 8}}, []byte{0xFF, 0x00}},
+		{"PrintNVBitImage", func(d *commands.Driver) error { return d.PrintNVBitImage(1, 3) }, "", []any{u8(1), u8(3)}, nil},
+		{"DefineNVBitImage", func(d *commands.Driver) error {
+			return d.DefineNVBitImage(1, 1, 0, 2, 0, make([]byte, 1*2*8))
+		}, "", []any{u8(1)}, append([]byte{1, 0, 2, 0}, make([]byte, 1*2*8)...)},
+		{"DefineDownloadedBitImage", func(d *commands.Driver) error {
+			return d.DefineDownloadedBitImage(2, 3, make([]byte, 2*3*8))
+		}, "", []any{commands.Size{Width: 16, Height: 24}}, make([]byte, 2*3*8)},
+		{"PrintNVBitImageMode", func(d *commands.Driver) error { return d.PrintNVBitImageMode(1, 0) }, "", []any{u8(1), u8(0)}, nil},
+		{"PrintRasterBitImage", func(d *commands.Driver) error {
+			return d.PrintRasterBitImage(1, 48, 0, 120, 0, make([]byte, 48*120))
+		}, "", []any{u8(1), commands.Size{Width: 384, Height: 120}}, make([]byte, 48*120)},
+		{"RecoverAndRestartPrint", func(d *commands.Driver) error { return d.RecoverAndRestartPrint() }, "", nil, nil},
+		{"RecoverAndCancelPrint", func(d *commands.Driver) error { return d.RecoverAndCancelPrint() }, "", nil, nil},
+		{"SendPulseToPin", func(d *commands.Driver) error { return d.SendPulseToPin(true, 2) }, "", []any{true, u8(2)}, nil},
+		{"SetBeepPrompt", func(d *commands.Driver) error { return d.SetBeepPrompt(2, 3) }, "", []any{u8(2), u8(3)}, nil},
+		{"GeneratePulse", func(d *commands.Driver) error { return d.GeneratePulse(true, 25, 250) }, "", []any{true, u8(25), u8(250)}, nil},
+		{"DisablePanelButtons", func(d *commands.Driver) error { return d.DisablePanelButtons(1) }, "", []any{u8(1)}, nil},
+		{"Cut", func(d *commands.Driver) error { return d.Cut() }, "", nil, nil},
+		{"ToggleMacroDefinition", func(d *commands.Driver) error { return d.ToggleMacroDefinition() }, "", nil, nil},
+		{"ExecuteMacro", func(d *commands.Driver) error { return d.ExecuteMacro(2, 10, 0) }, "", []any{u8(2), u8(10), u8(0)}, nil},
+		{"ToggleASB", func(d *commands.Driver) error { return d.ToggleASB(0x0C) }, "", []any{u8(0x0C)}, nil},
+		{"SetMotionUnits", func(d *commands.Driver) error { return d.SetMotionUnits(200, 100) }, "", []any{u8(200), u8(100)}, nil},
+		{"PrintTestPage", func(d *commands.Driver) error { return d.PrintTestPage() }, "", nil, nil},
+		{"SetPeripheralDevice", func(d *commands.Driver) error { return d.SetPeripheralDevice(1) }, "", []any{u8(1)}, nil},
+		{"FeedMarkedPaper", func(d *commands.Driver) error { return d.FeedMarkedPaper() }, "", nil, nil},
+		{"SelectCounterPrintMode", func(d *commands.Driver) error { return d.SelectCounterPrintMode(0) }, "", []any{u8(0)}, nil},
+		{"SelectCountMode", func(d *commands.Driver) error { return d.SelectCountMode(1, 0, 100, 0, 1, 1) }, "", []any{u8(1), u8(0), u8(100), u8(0), u8(1), u8(1)}, nil},
+		{"SetCounterValue", func(d *commands.Driver) error { return d.SetCounterValue(1, 0) }, "", []any{u8(1), u8(0)}, nil},
+		{"PrintCounter", func(d *commands.Driver) error { return d.PrintCounter() }, "", nil, nil},
+
+		// Lengths are sent with the command taking motion units
+		{"SetAbsolutePrintPositionTo", func(d *commands.Driver) error { return d.SetAbsolutePrintPositionTo(commands.Dots(10)) }, "SetAbsolutePrintPosition", []any{u8(10), u8(0)}, nil},
+		{"SetRelativePrintPositionBy", func(d *commands.Driver) error { return d.SetRelativePrintPositionBy(commands.Dots(10)) }, "SetRelativePrintPosition", []any{u8(10), u8(0)}, nil},
+		{"SetLeftMarginTo", func(d *commands.Driver) error { return d.SetLeftMarginTo(commands.Dots(10)) }, "SetLeftMargin", []any{u8(10), u8(0)}, nil},
+		{"SetPrintingAreaWidthTo", func(d *commands.Driver) error { return d.SetPrintingAreaWidthTo(commands.Dots(384)) }, "SetPrintingAreaWidth", []any{u8(378 % 256), u8(378 / 256)}, nil},
+		{"SetLineSpacingTo", func(d *commands.Driver) error { return d.SetLineSpacingTo(commands.Dots(30)) }, "SetLineSpacing", []any{u8(59)}, nil},
+		{"PrintAndFeedBy", func(d *commands.Driver) error { return d.PrintAndFeedBy(commands.Dots(10)) }, "PrintAndFeedNDotsLines", []any{u8(20)}, nil},
+		{"SetAbsoluteVerticalPrintPositionInPageModeTo", func(d *commands.Driver) error {
+			return d.SetAbsoluteVerticalPrintPositionInPageModeTo(commands.Dots(10))
+		}, "SetAbsoluteVerticalPrintPositionInPageMode", []any{u8(20), u8(0)}, nil},
+		{"SetRelativeVerticalPrintPositionInPageModeBy", func(d *commands.Driver) error {
+			return d.SetRelativeVerticalPrintPositionInPageModeBy(commands.Dots(10))
+		}, "SetRelativeVerticalPrintPositionInPageMode", []any{u8(20), u8(0)}, nil},
+	} {
+		d := commands.NewBuffer()
+		if err := tt.call(d); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+
+		want := tt.want
+		if want == "" {
+			want = tt.name
+		}
+
+		cmds, err := commands.Decode(d.Bytes())
+		if err != nil {
+			t.Errorf("%s: decoding % x: %v", tt.name, d.Bytes(), err)
+			continue
+		}
+
+		if len(cmds) != 1 || cmds[0].Name != want {
+			t.Errorf("%s: decoded %v, want a single %s", tt.name, cmds, want)
+			continue
+		}
+
+		if !reflect.DeepEqual(cmds[0].Args, tt.args) {
+			t.Errorf("%s: decoded arguments %#v, want %#v", tt.name, cmds[0].Args, tt.args)
+		}
+
+		if !bytes.Equal(cmds[0].Data, tt.data) {
+			t.Errorf("%s: decoded data % x, want % x", tt.name, cmds[0].Data, tt.data)
+		}
+	}
+}
+
+// The query requests aren't buffered, their bytes are decoded instead
+func TestDecodeQueries(t *testing.T) {
+	for _, tt := range []struct {
+		req  []byte
+		want string
+		args []any
+	}{
+		{[]byte{commands.DLE, commands.EOT, 2}, "TransmitRealTimeStatus", []any{uint8(2)}},
+		{[]byte{commands.GS, 'I', 1}, "TransmitPrinterID", []any{commands.PrinterIDInfo(1)}},
+		{[]byte{commands.GS, 'r', 1}, "TransmitStatus", []any{uint8(1)}},
+	} {
+		cmd, n, err := commands.DecodeNext(tt.req)
+		if err != nil || n != len(tt.req) {
+			t.Errorf("% x: used %d bytes, %v", tt.req, n, err)
+			continue
+		}
+
+		if cmd.Name != tt.want || !reflect.DeepEqual(cmd.Args, tt.args) {
+			t.Errorf("% x: got %v, want %s%v", tt.req, cmd, tt.want, tt.args)
+		}
+	}
+}
+
+func TestDecodeUnknownAndTruncated(t *testing.T) {
+	cmds, err := commands.Decode([]byte{0x01, commands.ESC, '@', commands.GS, 'V'})
+
+	var decodeErr *commands.DecodeError
+	if !errors.As(err, &decodeErr) || decodeErr.Offset != 0 || !errors.Is(err, commands.ErrUnknownCommand) {
+		t.Errorf("got %v, want ErrUnknownCommand at offset 0", err)
+	}
+
+	if len(cmds) != 2 || cmds[0].Name != "Unknown" || cmds[1].Name != "Initialize" || cmds[1].Offset != 1 {
+		t.Errorf("got %v, want Unknown then Initialize", cmds)
+	}
+
+	if _, _, err := commands.DecodeNext([]byte{commands.GS, 'V'}); !errors.Is(err, commands.ErrTruncatedCommand) {
+		t.Errorf("got %v, want ErrTruncatedCommand", err)
+	}
+}
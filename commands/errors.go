@@ -0,0 +1,111 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Error categories, matched with errors.Is on a *CommandError
+// ErrTimeout is the category of the timeouts.
+var (
+	ErrInvalidArgument = errors.New("invalid argument")
+	ErrTransport       = errors.New("transport error")
+	ErrProtocol        = errors.New("protocol error")
+	ErrPrinterState    = errors.New("printer state error") // The model or its current state can't run the command
+)
+
+// Describes why a command failed
+// errors.Is matches both the category (Kind) and the cause (Err), so
+// existing checks like errors.Is(err, ErrInvalidBarCodeLength) keep
+// working.
+type CommandError struct {
+	Command string // Driver method, e.g. "SetBeepPrompt"
+	Param   string // Parameter at fault, empty when the whole command failed
+	Value   any    // Value given for Param
+	Allowed string // Accepted values for Param, e.g. "1 <= n <= 9"
+	Kind    error  // ErrInvalidArgument, ErrTransport, ErrTimeout, ErrProtocol or ErrPrinterState
+	Err     error
+}
+
+func (e *CommandError) Error() string {
+	msg := e.Command + ": "
+
+	if e.Param != "" {
+		msg += fmt.Sprintf("%s = %v: ", e.Param, e.Value)
+	}
+
+	msg += e.Err.Error()
+
+	if e.Allowed != "" {
+		msg += " (allowed: " + e.Allowed + ")"
+	}
+
+	return msg
+}
+
+func (e *CommandError) Unwrap() []error {
+	if e.Kind == nil {
+		return []error{e.Err}
+	}
+
+	return []error{e.Kind, e.Err}
+}
+
+// Rejects a parameter before anything is sent
+func argError(command, param string, value any, allowed string, err error) error {
+	return &CommandError{
+		Command: command,
+		Param:   param,
+		Value:   value,
+		Allowed: allowed,
+		Kind:    ErrInvalidArgument,
+		Err:     err,
+	}
+}
+
+// Rejects a command the printer profile doesn't support
+func unsupported(command string) error {
+	return stateError(command, ErrUnsupportedCommand)
+}
+
+// Reports a printer that can't carry out command as it is
+func stateError(command string, err error) error {
+	return &CommandError{Command: command, Kind: ErrPrinterState, Err: err}
+}
+
+// Reports a reply that doesn't follow the protocol
+func protocolError(command string, err error) error {
+	return &CommandError{Command: command, Kind: ErrProtocol, Err: err}
+}
+
+// Attributes an I/O error to the command that caused it
+// Errors that already are a *CommandError are returned as is.
+func commandError(command string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var cmdErr *CommandError
+	if errors.As(err, &cmdErr) {
+		return err
+	}
+
+	return &CommandError{Command: command, Kind: errorKind(err), Err: err}
+}
+
+// Cancellations are not categorised, a printer that stayed offline is in
+// the way, the rest of the I/O errors are either timeouts or transport
+// problems
+func errorKind(err error) error {
+	switch {
+	case errors.Is(err, ErrTimeout):
+		return ErrTimeout
+	case errors.Is(err, ErrNotReady):
+		return ErrPrinterState
+	case errors.Is(err, context.Canceled):
+		return nil
+	}
+
+	return ErrTransport
+}
@@ -0,0 +1,134 @@
+package commands_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/cyb3rjerry/rongta-escpos/commands"
+)
+
+func TestCommandErrorMessage(t *testing.T) {
+	for _, tt := range []struct {
+		err  *commands.CommandError
+		want string
+	}{
+		{&commands.CommandError{
+			Command: "SetBeepPrompt",
+			Param:   "n",
+			Value:   10,
+			Allowed: "1 <= n <= 9",
+			Kind:    commands.ErrInvalidArgument,
+			Err:     commands.ErrInvalidNumberOfBeeps,
+		}, "SetBeepPrompt: n = 10: invalid number of beeps (allowed: 1 <= n <= 9)"},
+		{&commands.CommandError{
+			Command: "Cut",
+			Kind:    commands.ErrTransport,
+			Err:     io.ErrClosedPipe,
+		}, "Cut: io: read/write on closed pipe"},
+	} {
+		if got := tt.err.Error(); got != tt.want {
+			t.Errorf("got %q, want %q", got, tt.want)
+		}
+	}
+}
+
+// The category and the error returned before CommandError both match
+func TestCommandErrorIs(t *testing.T) {
+	err := commands.NewBuffer().PrintBarCode(3, commands.EAN13, []byte("123"))
+
+	if !errors.Is(err, commands.ErrInvalidArgument) || !errors.Is(err, commands.ErrInvalidBarCodeLength) {
+		t.Errorf("got %v, want ErrInvalidArgument and ErrInvalidBarCodeLength", err)
+	}
+
+	if errors.Is(err, commands.ErrTransport) || errors.Is(err, commands.ErrTimeout) {
+		t.Errorf("%v matches another category", err)
+	}
+
+	var cmdErr *commands.CommandError
+	if !errors.As(err, &cmdErr) || cmdErr.Command != "PrintBarCode" {
+		t.Errorf("got %#v, want a CommandError for PrintBarCode", err)
+	}
+}
+
+func TestSelectCharacterSizeRejectsHeight(t *testing.T) {
+	for _, h := range []uint8{0, 9} {
+		d := commands.NewBuffer()
+
+		err := d.SelectCharacterSize(1, h)
+		if !errors.Is(err, commands.ErrInvalidCharHeight) || !errors.Is(err, commands.ErrInvalidArgument) {
+			t.Errorf("h = %d: got %v, want ErrInvalidCharHeight", h, err)
+		}
+
+		var cmdErr *commands.CommandError
+		if !errors.As(err, &cmdErr) || cmdErr.Param != "h" || cmdErr.Value != h {
+			t.Errorf("h = %d: got %#v, want h at fault", h, err)
+		}
+
+		if len(d.Bytes()) != 0 {
+			t.Errorf("h = %d: sent % x", h, d.Bytes())
+		}
+	}
+}
+
+func TestCommandErrorKeepsExistingCommandError(t *testing.T) {
+	inner := &commands.CommandError{Command: "Initialize", Kind: commands.ErrTransport, Err: io.EOF}
+
+	if err := commands.CommandErrorOf("Job", inner); err != error(inner) {
+		t.Errorf("got %v, want the CommandError of Initialize", err)
+	}
+
+	if err := commands.CommandErrorOf("Initialize", nil); err != nil {
+		t.Errorf("got %v for no error", err)
+	}
+}
+
+func TestErrorKind(t *testing.T) {
+	for _, tt := range []struct {
+		err  error
+		want error
+	}{
+		{&commands.TimeoutError{Op: "read", Err: context.DeadlineExceeded}, commands.ErrTimeout},
+		{context.Canceled, nil},
+		{os.ErrClosed, commands.ErrTransport},
+		{io.EOF, commands.ErrTransport},
+	} {
+		if got := commands.ErrorKind(tt.err); got != tt.want {
+			t.Errorf("%v: got kind %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+// I/O errors of the driver are attributed to the command
+func TestDriverErrorKinds(t *testing.T) {
+	d, conn := newMutedDriver(t, 0)
+
+	_, err := withTimeout(t, d, 20*time.Millisecond).GetAutocutterStatus()
+	if !errors.Is(err, commands.ErrTimeout) {
+		t.Errorf("got %v, want ErrTimeout", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err = d.WithContext(ctx).Initialize()
+	if !errors.Is(err, context.Canceled) || errors.Is(err, commands.ErrTransport) {
+		t.Errorf("got %v, want an uncategorised cancellation", err)
+	}
+
+	conn.muted.Store(false)
+	conn.Close()
+
+	err = d.Initialize()
+	if !errors.Is(err, commands.ErrTransport) {
+		t.Errorf("got %v, want ErrTransport", err)
+	}
+
+	var cmdErr *commands.CommandError
+	if !errors.As(err, &cmdErr) || cmdErr.Command != "Initialize" {
+		t.Errorf("got %#v, want a CommandError for Initialize", err)
+	}
+}
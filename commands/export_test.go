@@ -0,0 +1,14 @@
+package commands
+
+// Let the tests shorten the time a late reply is waited for and the time
+// Identify gives each query
+var (
+	LateReplyTimeout = &lateReplyTimeout
+	IdentifyTimeout  = &identifyTimeout
+)
+
+// Let the tests attribute errors to commands directly
+var (
+	CommandErrorOf = commandError
+	ErrorKind      = errorKind
+)
@@ -36,14 +36,12 @@ var (
 
 // Write string to printer buffer
 func (p *Driver) WriteStringToBuffer(s string) error {
-	_, err := p.rwc.Write([]byte(s))
-	return err
+	return p.exec("WriteStringToBuffer", []byte(s))
 }
 
 // Set the right-side character spacing to n X 0.125mm
 func (p *Driver) SetRightSideChar(n uint8) error {
-	_, err := p.rwc.Write([]byte{ESC, SP, n})
-	return err
+	return p.exec("SetRightSideChar", []byte{ESC, SP, n})
 }
 
 // Set print mode(s)
@@ -71,15 +69,13 @@ func (p *Driver) SetPrintMode(pm *PrintMode) error {
 		uint8Mode |= 0x80
 	}
 
-	_, err := p.rwc.Write([]byte{ESC, BANG, uint8Mode})
-	return err
+	return p.exec("SetPrintMode", []byte{ESC, BANG, uint8Mode})
 }
 
 // Set absolute print position
 // nL, nH = (nL + nH * 256) X 0.125mm
 func (p *Driver) SetAbsolutePrintPosition(nL, nH uint8) error {
-	_, err := p.rwc.Write([]byte{ESC, '$', nL, nH})
-	return err
+	return p.exec("SetAbsolutePrintPosition", []byte{ESC, '$', nL, nH})
 }
 
 // Select/Cancel user-defined character
@@ -88,14 +84,13 @@ func (p *Driver) SetAbsolutePrintPosition(nL, nH uint8) error {
 // Note: When the user-defined character set is canceled, the resident
 // character set is automatically selected.
 func (p *Driver) SelectUserDefinedCharacter(n uint8) error {
-	_, err := p.rwc.Write([]byte{ESC, '%', n})
-	return err
+	return p.exec("SelectUserDefinedCharacter", []byte{ESC, '%', n})
 }
 
 // [Unimplemented] TODO: Implement this
 // Define user-defined characters
 func (p *Driver) DefineUserDefinedCharacters(n uint8, data []uint8) error {
-	_, err := p.rwc.Write(append([]byte{ESC, '&', n}, data...))
+	err := p.exec("DefineUserDefinedCharacters", append([]byte{ESC, '&', n}, data...))
 
 	panic("TODO: Implement DefineUserDefinedCharacters")
 	return err
@@ -117,57 +112,57 @@ func (p *Driver) SetUnderline(u Underline) error {
 		underlineBit = 2
 	}
 
-	_, err := p.rwc.Write([]byte{ESC, DASH, underlineBit})
-	return err
+	return p.exec("SetUnderline", []byte{ESC, DASH, underlineBit})
 }
 
 // Select default line spacing
 func (p *Driver) SetDefaultLineSpacing() error {
-	_, err := p.rwc.Write([]byte{ESC, '2'})
-	return err
+	return p.exec("SetDefaultLineSpacing", []byte{ESC, '2'})
 }
 
 // Set line spacing
 // Line spacing = n X 0.125mm
 func (p *Driver) SetLineSpacing(n uint8) error {
-	_, err := p.rwc.Write([]byte{ESC, '3', n})
-	return err
+	return p.exec("SetLineSpacing", []byte{ESC, '3', n})
 }
 
 // Initialize the printer
+// Also restores the default motion units
 func (p *Driver) Initialize() error {
-	_, err := p.rwc.Write([]byte{ESC, '@'})
-	return err
+	return p.exclusive(func() error {
+		err := p.command("Initialize", []byte{ESC, '@'})
+		if err == nil {
+			p.setMotionUnits(0, 0)
+		}
+
+		return err
+	})
 }
 
 // Set horizontal tab positions
 // n = 0, 1, 2, ..., 255
 // k = 0, 1, 2, ..., 32
 func (p *Driver) SetHorizontalTabPositions(n, k uint8) error {
-	_, err := p.rwc.Write([]byte{ESC, 'D', n, k})
-	return err
+	return p.exec("SetHorizontalTabPositions", []byte{ESC, 'D', n, k})
 }
 
 // Set emphasized mode
 // When the LSB of n is 0, emphasized mode is turned off.
 // When the LSB of n is 1, emphasized mode is turned on.
 func (p *Driver) SetEmphasizedMode(n uint8) error {
-	_, err := p.rwc.Write([]byte{ESC, 'E', n})
-	return err
+	return p.exec("SetEmphasizedMode", []byte{ESC, 'E', n})
 }
 
 // Set double-strike mode
 // When the LSB of n is 0, double-strike mode is turned off.
 // When the LSB of n is 1, double-strike mode is turned on.
 func (p *Driver) SetDoubleStrikeMode(n uint8) error {
-	_, err := p.rwc.Write([]byte{ESC, 'G', n})
-	return err
+	return p.exec("SetDoubleStrikeMode", []byte{ESC, 'G', n})
 }
 
 // Print and feed n lines
 func (p *Driver) PrintAndFeedNLines(n uint8) error {
-	_, err := p.rwc.Write([]byte{ESC, 'd', n})
-	return err
+	return p.exec("PrintAndFeedNLines", []byte{ESC, 'd', n})
 }
 
 // Set character font
@@ -177,8 +172,7 @@ func (p *Driver) SetCharacterFont(f Font) error {
 		n = 1
 	}
 
-	_, err := p.rwc.Write([]byte{ESC, 'M', n})
-	return err
+	return p.exec("SetCharacterFont", []byte{ESC, 'M', n})
 }
 
 // Rotate clockwise 90 degrees mode
@@ -191,26 +185,22 @@ func (p *Driver) RotateClockwise90Degrees(r bool) error {
 		bit = 0x01
 	}
 
-	_, err := p.rwc.Write([]byte{ESC, 'V', bit})
-	return err
+	return p.exec("RotateClockwise90Degrees", []byte{ESC, 'V', bit})
 }
 
 // Set relative print position
 func (p *Driver) SetRelativePrintPosition(nL, nH uint8) error {
-	_, err := p.rwc.Write([]byte{ESC, BACKSLASH, nL, nH})
-	return err
+	return p.exec("SetRelativePrintPosition", []byte{ESC, BACKSLASH, nL, nH})
 }
 
 // Set justification
 func (p *Driver) SetJustification(j Justify) error {
-	_, err := p.rwc.Write([]byte{ESC, 'a', uint8(j)})
-	return err
+	return p.exec("SetJustification", []byte{ESC, 'a', uint8(j)})
 }
 
 // Print and feed n lines
 func (p *Driver) PrintAndFeedNDotsLines(n uint8) error {
-	_, err := p.rwc.Write([]byte{ESC, 'J', n})
-	return err
+	return p.exec("PrintAndFeedNDotsLines", []byte{ESC, 'J', n})
 }
 
 // Select character size
@@ -237,7 +227,7 @@ func (p *Driver) SelectCharacterSize(w, h uint8) error {
 	case 8:
 		charSizeBit = 0x70
 	default:
-		return errors.New("invalid character width")
+		return argError("SelectCharacterSize", "w", w, "1 <= w <= 8", ErrInvalidCharWidth)
 	}
 
 	switch h {
@@ -257,46 +247,46 @@ func (p *Driver) SelectCharacterSize(w, h uint8) error {
 		charSizeBit |= 0x06
 	case 8:
 		charSizeBit |= 0x07
+	default:
+		return argError("SelectCharacterSize", "h", h, "1 <= h <= 8", ErrInvalidCharHeight)
 	}
 
-	_, err := p.rwc.Write([]byte{GS, '!', charSizeBit})
-	return err
+	return p.exec("SelectCharacterSize", []byte{GS, '!', charSizeBit})
 }
 
 // Turn white/black reverse printing mode
 // When the LSB of n is 0, white/black reverse printing mode is turned off.
 // When the LSB of n is 1, white/black reverse printing mode is turned on.
 func (p *Driver) SetWhiteBlackReversePrintingMode(n uint8) error {
-	_, err := p.rwc.Write([]byte{GS, 'B', n})
-	return err
+	return p.exec("SetWhiteBlackReversePrintingMode", []byte{GS, 'B', n})
 }
 
 // Set left margin
 // nL, nH = (nL + nH * 256) X 0.125mm
 func (p *Driver) SetLeftMargin(nL, nH uint8) error {
-	_, err := p.rwc.Write([]byte{GS, 'L', nL, nH})
-	return err
+	return p.exec("SetLeftMargin", []byte{GS, 'L', nL, nH})
 }
 
 // Select cut mode and cut paper to cutting position n
 // Feeds paper (cutting position + [n x 0.125mm])
 func (p *Driver) SelectCutModeAndCutPaper(n uint8) error {
-	_, err := p.rwc.Write([]byte{GS, 'V', 0x66, n})
-	return err
+	if p.profile != nil && !p.profile.Cutter {
+		return unsupported("SelectCutModeAndCutPaper")
+	}
+
+	return p.exec("SelectCutModeAndCutPaper", []byte{GS, 'V', 0x66, n})
 }
 
 // Set printing area width
 // nL, nH = (nL + nH x 256) x 0.125mm
 func (p *Driver) SetPrintingAreaWidth(nL, nH uint8) error {
-	_, err := p.rwc.Write([]byte{GS, 'W', nL, nH})
-	return err
+	return p.exec("SetPrintingAreaWidth", []byte{GS, 'W', nL, nH})
 }
 
 // Prints the data in the print buffer collectively
 // and returns to standard mode.
 func (p *Driver) PrintBufferAndReturnToStandardMode() error {
-	_, err := p.rwc.Write([]byte{FF})
-	return err
+	return p.exec("PrintBufferAndReturnToStandardMode", []byte{FF})
 }
 
 // When in page mode, all data in the print buffer is printed
@@ -304,20 +294,21 @@ func (p *Driver) PrintBufferAndReturnToStandardMode() error {
 // After printing, the printer does not delete the set value of
 // ESC T and ESC W
 func (p *Driver) PrintBufferInPageMode() error {
-	_, err := p.rwc.Write([]byte{ESC, FF})
-	return err
+	return p.exec("PrintBufferInPageMode", []byte{ESC, FF})
 }
 
 // Selects page mode
 func (p *Driver) SelectPageMode() error {
-	_, err := p.rwc.Write([]byte{ESC, 'L'})
-	return err
+	if p.profile != nil && !p.profile.PageMode {
+		return unsupported("SelectPageMode")
+	}
+
+	return p.exec("SelectPageMode", []byte{ESC, 'L'})
 }
 
 // Selects standard mode
 func (p *Driver) SelectStandardMode() error {
-	_, err := p.rwc.Write([]byte{ESC, 'S'})
-	return err
+	return p.exec("SelectStandardMode", []byte{ESC, 'S'})
 }
 
 // Select print direction in page mode
@@ -328,10 +319,9 @@ func (p *Driver) SelectStandardMode() error {
 // 3: top to bottom, starting upper right corner
 func (p *Driver) SelectPrintDirectionInPageMode(a uint8) error {
 	if a > 3 {
-		return ErrInvalidPrintDirection
+		return argError("SelectPrintDirectionInPageMode", "a", a, "0 <= a <= 3", ErrInvalidPrintDirection)
 	}
-	_, err := p.rwc.Write([]byte{ESC, 'T', a})
-	return err
+	return p.exec("SelectPrintDirectionInPageMode", []byte{ESC, 'T', a})
 }
 
 // Set print area in page mode
@@ -345,19 +335,16 @@ func (p *Driver) SelectPrintDirectionInPageMode(a uint8) error {
 // dy = ((dyL + dyH x 256) x 0.125mm)
 func (p *Driver) SetPrintAreaInPageMode(xL, xH, yL, yH, dxL, dxH, dyL, dyH uint8) error {
 	// TODO: Handle error on dL, dH = 0
-	_, err := p.rwc.Write([]byte{ESC, 'W', xL, xH, yL, yH, dxL, dxH, dyL, dyH})
-	return err
+	return p.exec("SetPrintAreaInPageMode", []byte{ESC, 'W', xL, xH, yL, yH, dxL, dxH, dyL, dyH})
 }
 
 // Set absolute vertical print position in page mode
 // nL, nH = (nL + nH x 256) x 0.125mm
 func (p *Driver) SetAbsoluteVerticalPrintPositionInPageMode(nL, nH uint8) error {
-	_, err := p.rwc.Write([]byte{GS, DOLLAR, nL, nH})
-	return err
+	return p.exec("SetAbsoluteVerticalPrintPositionInPageMode", []byte{GS, DOLLAR, nL, nH})
 }
 
 // Set relative vertical print position in page mode
 func (p *Driver) SetRelativeVerticalPrintPositionInPageMode(nL, nH uint8) error {
-	_, err := p.rwc.Write([]byte{GS, BACKSLASH, nL, nH})
-	return err
+	return p.exec("SetRelativeVerticalPrintPositionInPageMode", []byte{GS, BACKSLASH, nL, nH})
 }
@@ -0,0 +1,133 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// Printer type ID bitmasks
+	TYPE_ID_MULTI_BYTE_MASK uint8 = 0x01 // Multi-byte characters supported
+	TYPE_ID_CUTTER_MASK     uint8 = 0x02 // Autocutter installed
+)
+
+// Time given to each GS I query of Identify
+var identifyTimeout = 2 * time.Second
+
+var (
+	ErrInvalidPrinterIDReply = errors.New("invalid printer ID reply")
+)
+
+// Printer identity reported by GS I
+type PrinterInfo struct {
+	ModelID      uint8
+	TypeID       uint8 // See the TYPE_ID masks
+	Firmware     string
+	Manufacturer string
+	Name         string
+	SerialNumber string
+}
+
+// One line summary, e.g. for logs
+func (i PrinterInfo) String() string {
+	name := strings.TrimSpace(i.Manufacturer + " " + i.Name)
+	if name == "" {
+		name = "unknown printer"
+	}
+
+	return fmt.Sprintf("%s (model %#02x, type %#02x, firmware %s, serial %s)",
+		name, i.ModelID, i.TypeID, i.Firmware, i.SerialNumber)
+}
+
+// Reports whether the type ID announces an autocutter
+func (i PrinterInfo) Cutter() bool {
+	return i.TypeID&TYPE_ID_CUTTER_MASK != 0
+}
+
+// Queries the model and type IDs, the firmware version, manufacturer, name
+// and serial number in a row
+// Replies that aren't framed as expected fail with
+// ErrInvalidPrinterIDReply and ErrProtocol. Each query times out after 2s,
+// or sooner when the context ends, so a printer that doesn't answer GS I
+// can't hold the connection.
+func (p *Driver) Identify() (PrinterInfo, error) {
+	var info PrinterInfo
+
+	err := p.Job(func(d *Driver) error {
+		for _, id := range []struct {
+			n   PrinterIDInfo
+			dst *uint8
+		}{
+			{PrinterModelID, &info.ModelID},
+			{PrinterTypeID, &info.TypeID},
+		} {
+			v, err := d.printerIDByte(id.n)
+			if err != nil {
+				return err
+			}
+			*id.dst = v
+		}
+
+		for _, id := range []struct {
+			n   PrinterIDInfo
+			dst *string
+		}{
+			{FirmwareVersion, &info.Firmware},
+			{ManufacturerID, &info.Manufacturer},
+			{PrinterName, &info.Name},
+			{SerialNumber, &info.SerialNumber},
+		} {
+			v, err := d.printerIDBlock(id.n)
+			if err != nil {
+				return err
+			}
+			*id.dst = v
+		}
+
+		return nil
+	})
+	if err != nil {
+		return PrinterInfo{}, err
+	}
+
+	return info, nil
+}
+
+// Single byte ID, the caller has exclusive access
+func (p *Driver) printerIDByte(n PrinterIDInfo) (uint8, error) {
+	ctx, cancel := context.WithTimeout(p.ctx, identifyTimeout)
+	defer cancel()
+
+	reply, err := p.WithContext(ctx).TransmitPrinterID(n)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(reply) != 1 {
+		err := fmt.Errorf("%w: GS I %d replied % x", ErrInvalidPrinterIDReply, n, reply)
+		return 0, protocolError("TransmitPrinterID", err)
+	}
+
+	return reply[0], nil
+}
+
+// Text ID without its framing, the caller has exclusive access
+func (p *Driver) printerIDBlock(n PrinterIDInfo) (string, error) {
+	ctx, cancel := context.WithTimeout(p.ctx, identifyTimeout)
+	defer cancel()
+
+	reply, err := p.WithContext(ctx).TransmitPrinterID(n)
+	if err != nil {
+		return "", err
+	}
+
+	if len(reply) < 2 || reply[0] != BLOCK_REPLY_HEADER || reply[len(reply)-1] != NUL {
+		err := fmt.Errorf("%w: GS I %d replied % x", ErrInvalidPrinterIDReply, n, reply)
+		return "", protocolError("TransmitPrinterID", err)
+	}
+
+	return string(reply[1 : len(reply)-1]), nil
+}
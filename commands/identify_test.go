@@ -0,0 +1,137 @@
+package commands_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cyb3rjerry/rongta-escpos/commands"
+	"github.com/cyb3rjerry/rongta-escpos/commands/commandstest"
+	"github.com/cyb3rjerry/rongta-escpos/rongtasim"
+)
+
+func TestTransmitPrinterIDBytes(t *testing.T) {
+	for _, tt := range []struct {
+		n     commands.PrinterIDInfo
+		reply []byte
+	}{
+		{commands.PrinterModelID, []byte{0x20}},
+		{commands.PrinterTypeID, []byte{commands.TYPE_ID_CUTTER_MASK}},
+		{commands.FirmwareVersion, []byte("_1.00\x00")},
+		{commands.ManufacturerID, []byte("_RONGTA\x00")},
+		{commands.PrinterName, []byte("_RP326\x00")},
+		{commands.SerialNumber, []byte("_SIM00001\x00")},
+	} {
+		d, conn := commandstest.NewDriver(t)
+		conn.Reply("TransmitPrinterID", tt.reply...)
+
+		if _, err := withTimeout(t, d, time.Second).TransmitPrinterID(tt.n); err != nil {
+			t.Fatalf("TransmitPrinterID(%d): %v", tt.n, err)
+		}
+
+		want := []byte{commands.GS, 'I', uint8(tt.n)}
+		if got := conn.Written(); !bytes.Equal(got, want) {
+			t.Errorf("TransmitPrinterID(%d) sent % x, want % x", tt.n, got, want)
+		}
+	}
+}
+
+func TestTransmitPrinterIDRejectsUnknownIDs(t *testing.T) {
+	d, conn := commandstest.NewDriver(t)
+
+	for _, n := range []commands.PrinterIDInfo{0, 3, 64, 69} {
+		_, err := d.TransmitPrinterID(n)
+		if !errors.Is(err, commands.ErrInvalidTypePrinterID) || !errors.Is(err, commands.ErrInvalidArgument) {
+			t.Errorf("TransmitPrinterID(%d) returned %v, want ErrInvalidTypePrinterID", n, err)
+		}
+	}
+
+	if written := conn.Written(); len(written) != 0 {
+		t.Errorf("sent % x for invalid IDs", written)
+	}
+}
+
+func TestPrinterInfo(t *testing.T) {
+	info := commands.PrinterInfo{
+		ModelID:      0x20,
+		TypeID:       commands.TYPE_ID_CUTTER_MASK,
+		Firmware:     "1.00",
+		Manufacturer: "RONGTA",
+		Name:         "RP326",
+		SerialNumber: "SIM00001",
+	}
+
+	want := "RONGTA RP326 (model 0x20, type 0x02, firmware 1.00, serial SIM00001)"
+	if got := info.String(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	if !info.Cutter() {
+		t.Error("cutter not reported")
+	}
+
+	if (commands.PrinterInfo{}).Cutter() {
+		t.Error("cutter reported without the type ID bit")
+	}
+}
+
+func TestIdentify(t *testing.T) {
+	d, _ := newDriver(t)
+
+	info, err := withTimeout(t, d, time.Second).Identify()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := commands.PrinterInfo{
+		ModelID:      0x20,
+		TypeID:       commands.TYPE_ID_CUTTER_MASK,
+		Firmware:     "1.00",
+		Manufacturer: "RONGTA",
+		Name:         "RP326",
+		SerialNumber: "SIM00001",
+	}
+
+	if info != want {
+		t.Errorf("got %s, want %s", info, want)
+	}
+}
+
+func TestSingleByteIDLikeABlockHeader(t *testing.T) {
+	sim := rongtasim.New()
+	sim.SetPrinterID(uint8(commands.PrinterModelID), []byte{commands.BLOCK_REPLY_HEADER})
+
+	d := commands.NewDriver(sim)
+	defer d.Close()
+
+	id, err := withTimeout(t, d, time.Second).TransmitPrinterID(commands.PrinterModelID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(id) != 1 || id[0] != commands.BLOCK_REPLY_HEADER {
+		t.Errorf("got % x, want the single byte", id)
+	}
+}
+
+func TestIdentifyTimesOutWithoutContextDeadline(t *testing.T) {
+	defer func(timeout time.Duration) { *commands.IdentifyTimeout = timeout }(*commands.IdentifyTimeout)
+	*commands.IdentifyTimeout = 50 * time.Millisecond
+
+	d, conn := commandstest.NewDriver(t)
+
+	_, err := d.Identify()
+	if !errors.Is(err, commands.ErrTimeout) {
+		t.Fatalf("got %v, want ErrTimeout", err)
+	}
+
+	// The connection is free again
+	if err := withTimeout(t, d, time.Second).Cut(); err != nil {
+		t.Fatal(err)
+	}
+
+	conn.ExpectCommand(t, "TransmitPrinterID", commands.PrinterModelID)
+	conn.ExpectCommand(t, "Cut")
+	conn.ExpectNoMoreCommands(t)
+}
@@ -1,9 +1,15 @@
 package commands
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+)
 
 var (
-	ErrInvalidBitImageModevalue = errors.New("invalid m value")
+	ErrInvalidBitImageModevalue  = errors.New("invalid m value")
+	ErrInvalidNVBitImage         = errors.New("invalid NV bit image definition")
+	ErrNVMemoryExceeded          = fmt.Errorf("%w: NV bit images larger than the NV memory", ErrUnsupportedCommand)
+	ErrInvalidDownloadedBitImage = errors.New("invalid downloaded bit image definition")
 )
 
 // Select bit-image mode
@@ -18,11 +24,10 @@ var (
 // 0 <= nH <= 3
 func (p *Driver) SelectBitImageMode(m, nL, nH uint8, d []uint8) error {
 	if m != 0 && m != 1 && m != 32 && m != 33 {
-		return ErrInvalidBitImageModevalue
+		return argError("SelectBitImageMode", "m", m, "0, 1, 32 or 33", ErrInvalidBitImageModevalue)
 	}
 
-	_, err := p.rwc.Write(append([]byte{ESC, '*', m, nL, nH}, d...))
-	return err
+	return p.exec("SelectBitImageMode", append([]byte{ESC, '*', m, nL, nH}, d...))
 }
 
 // Print NV bit image
@@ -35,15 +40,15 @@ func (p *Driver) SelectBitImageMode(m, nL, nH uint8, d []uint8) error {
 // This command is not effective when the specified NV bit image
 // has not been defined
 func (p *Driver) PrintNVBitImage(n, m uint8) error {
-	_, err := p.rwc.Write([]byte{ESC, 'p', n, m})
-	return err
+	return p.exec("PrintNVBitImage", []byte{FS, 'p', n, m})
 }
 
-// [UNIMPLEMENTED] Doc is insanely unclear.
-// Define NV bit image
-// n: specifies the number of the defined NV bit image
-// xL, xH (xL + yH x 256) x 8 dots: specify the horizontal size of the bit image
-// yL, yH (yL + yH x 256) x 8 dots: specify the vertical size of the bit image
+// Define NV bit images
+// n: specifies the number of the defined NV bit images
+// xL, xH (xL + xH x 256) x 8 dots: specify the horizontal size of the first bit image
+// yL, yH (yL + yH x 256) x 8 dots: specify the vertical size of the first bit image
+// d: the (xL + xH x 256) x (yL + yH x 256) x 8 bytes of the first bit image,
+// followed by xL xH yL yH d1...dk for each of the other bit images
 //
 // 1 <= n <= 255,
 // 0 <= xL <= 255,
@@ -51,31 +56,85 @@ func (p *Driver) PrintNVBitImage(n, m uint8) error {
 // 0 <= yL <= 255,
 // 0 <= yH <= 3 (when 1 <= (yL + yH x 256) <= 288),
 // 0 <= d <= 255,
-// Total defined data area = 192K bytes.
+// Total defined data area = 192K bytes, or the NVMemory of the profile.
 //
 // Frequent write command executions may damage the NV
 // memory. Therefore, it is recommended to write the NV memory
 // 10 times or less a day.
 func (p *Driver) DefineNVBitImage(n, xL, xH, yL, yH uint8, d []uint8) error {
-	// TODO: Validate all of this
-	panic("unimplemented")
-	_, err := p.rwc.Write(append([]byte{ESC, 'q', n, xL, xH, yL, yH}, d...))
-	return err
+	if n == 0 {
+		return argError("DefineNVBitImage", "n", n, "1 <= n <= 255", ErrInvalidNVBitImage)
+	}
+
+	header, rest := []byte{xL, xH, yL, yH}, d
+	size := 0
+	for image := 1; image <= int(n); image++ {
+		if image > 1 {
+			if len(rest) < 4 {
+				return argError("DefineNVBitImage", "d", len(d), fmt.Sprintf("a header for bit image %d", image), ErrInvalidNVBitImage)
+			}
+			header, rest = rest[:4], rest[4:]
+		}
+
+		x, y := word(header[0], header[1]), word(header[2], header[3])
+		if x < 1 || x > 1023 {
+			return argError("DefineNVBitImage", "x", x, "1 <= (xL + xH x 256) <= 1023", ErrInvalidNVBitImage)
+		}
+
+		if y < 1 || y > 288 {
+			return argError("DefineNVBitImage", "y", y, "1 <= (yL + yH x 256) <= 288", ErrInvalidNVBitImage)
+		}
+
+		k := x * y * 8
+		if len(rest) < k {
+			return argError("DefineNVBitImage", "d", len(d), fmt.Sprintf("%d bytes for bit image %d", k, image), ErrInvalidNVBitImage)
+		}
+		rest = rest[k:]
+		size += k
+	}
+
+	if len(rest) > 0 {
+		return argError("DefineNVBitImage", "d", len(d), fmt.Sprintf("the data of %d bit images", n), ErrInvalidNVBitImage)
+	}
+
+	if p.profile != nil && p.profile.NVMemory > 0 && size > p.profile.NVMemory {
+		return stateError("DefineNVBitImage", ErrNVMemoryExceeded)
+	}
+
+	return p.exec("DefineNVBitImage", append([]byte{FS, 'q', n, xL, xH, yL, yH}, d...))
 }
 
 // Define downloaded bit images
-// x specifies the number of dots in the horizontal direction
-// y specifies the number of dots in the vertical direction
-// d specifies the bit image data
+// x specifies the number of dots in the horizontal direction (x x 8 dots)
+// y specifies the number of dots in the vertical direction (y x 8 dots)
+// d specifies the bit image data, x x y x 8 bytes
+//
+// 1 <= x <= 255,
+// 1 <= y <= 48,
+// x x y <= 1536
 //
 // The downloaded bit image definition is cleared when:
 // 1) ESC @ is executed.
 // 2) ESC & is executed.
 // 3) Printer is reset or the power is turned off.
 func (p *Driver) DefineDownloadedBitImage(x, y uint8, d []uint8) error {
-	panic("unimplemented")
-	_, err := p.rwc.Write(append([]byte{GS, 'v', 0, x, y}, d...))
-	return err
+	if x < 1 {
+		return argError("DefineDownloadedBitImage", "x", x, "1 <= x <= 255", ErrInvalidDownloadedBitImage)
+	}
+
+	if y < 1 || y > 48 {
+		return argError("DefineDownloadedBitImage", "y", y, "1 <= y <= 48", ErrInvalidDownloadedBitImage)
+	}
+
+	if int(x)*int(y) > 1536 {
+		return argError("DefineDownloadedBitImage", "x x y", int(x)*int(y), "x x y <= 1536", ErrInvalidDownloadedBitImage)
+	}
+
+	if len(d) != int(x)*int(y)*8 {
+		return argError("DefineDownloadedBitImage", "d", len(d), fmt.Sprintf("%d bytes", int(x)*int(y)*8), ErrInvalidDownloadedBitImage)
+	}
+
+	return p.exec("DefineDownloadedBitImage", append([]byte{GS, ASTERISK, x, y}, d...))
 }
 
 // [UNIMPLEMENTED] Doc is insanely unclear.
@@ -87,12 +146,11 @@ func (p *Driver) DefineDownloadedBitImage(x, y uint8, d []uint8) error {
 // m = 3: Quadruple mode (vDensity = 101.6dpi, hDensity = 101.6dpi)
 func (p *Driver) PrintDownloadedBitImage(m uint8) error {
 	if m > 3 {
-		return ErrInvalidBitImageModevalue
+		return argError("PrintDownloadedBitImage", "m", m, "0 <= m <= 3", ErrInvalidBitImageModevalue)
 	}
 	panic("unimplemented")
 
-	_, err := p.rwc.Write([]byte{GS, SLASH, m})
-	return err
+	return p.exec("PrintDownloadedBitImage", []byte{GS, SLASH, m})
 }
 
 // Prints NV bit image n using the mode specified by m.
@@ -103,11 +161,10 @@ func (p *Driver) PrintDownloadedBitImage(m uint8) error {
 // m = 3: Quadruple mode (vDensity = 101.6dpi, hDensity = 101.6dpi)
 func (p *Driver) PrintNVBitImageMode(n, m uint8) error {
 	if m > 3 {
-		return ErrInvalidBitImageModevalue
+		return argError("PrintNVBitImageMode", "m", m, "0 <= m <= 3", ErrInvalidBitImageModevalue)
 	}
 
-	_, err := p.rwc.Write([]byte{GS, 'p', n, m})
-	return err
+	return p.exec("PrintNVBitImageMode", []byte{GS, 'p', n, m})
 }
 
 // Print raster bit image
@@ -125,9 +182,8 @@ func (p *Driver) PrintNVBitImageMode(n, m uint8) error {
 // 0 <= yH <= 8 where 1 <= (yL + yH x 256) <= 4095
 func (p *Driver) PrintRasterBitImage(m, xL, xH, yL, yH uint8, d []uint8) error {
 	if m > 3 {
-		return ErrInvalidBitImageModevalue
+		return argError("PrintRasterBitImage", "m", m, "0 <= m <= 3", ErrInvalidBitImageModevalue)
 	}
 
-	_, err := p.rwc.Write(append([]byte{GS, 'v', '0', m, xL, xH, yL, yH}, d...))
-	return err
+	return p.exec("PrintRasterBitImage", append([]byte{GS, 'v', '0', m, xL, xH, yL, yH}, d...))
 }
@@ -0,0 +1,130 @@
+package commands_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/cyb3rjerry/rongta-escpos/commands"
+)
+
+func TestPrintNVBitImage(t *testing.T) {
+	got, err := encode(func(d *commands.Driver) error { return d.PrintNVBitImage(1, 3) })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []byte{commands.FS, 'p', 1, 3}
+	if !bytes.Equal(got, want) {
+		t.Errorf("sent % x, want % x", got, want)
+	}
+}
+
+func TestDefineDownloadedBitImage(t *testing.T) {
+	d := []byte{0xAA, 0x55, 0xAA, 0x55, 0xAA, 0x55, 0xAA, 0x55}
+
+	got, err := encode(func(drv *commands.Driver) error { return drv.DefineDownloadedBitImage(1, 1, d) })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := append([]byte{commands.GS, '*', 1, 1}, d...)
+	if !bytes.Equal(got, want) {
+		t.Errorf("sent % x, want % x", got, want)
+	}
+
+	for _, tt := range []struct {
+		x, y uint8
+		size int
+	}{
+		{0, 1, 0},
+		{1, 49, 49 * 8},
+		{40, 40, 40 * 40 * 8}, // Over 1536
+		{1, 1, 7},
+	} {
+		err := commands.NewBuffer().DefineDownloadedBitImage(tt.x, tt.y, make([]byte, tt.size))
+		if !errors.Is(err, commands.ErrInvalidDownloadedBitImage) {
+			t.Errorf("DefineDownloadedBitImage(%d, %d) with %d bytes returned %v, want ErrInvalidDownloadedBitImage", tt.x, tt.y, tt.size, err)
+		}
+	}
+}
+
+// Returns the FS q data of a bit image of x by y bytes, after its header
+// unless it is the first one
+func nvImage(first bool, x, y int) []byte {
+	var b []byte
+	if !first {
+		b = []byte{byte(x), byte(x >> 8), byte(y), byte(y >> 8)}
+	}
+
+	return append(b, make([]byte, x*y*8)...)
+}
+
+func TestDefineNVBitImage(t *testing.T) {
+	// Two images, 16 KiB each
+	data := append(nvImage(true, 64, 32), nvImage(false, 64, 32)...)
+
+	got, err := encode(func(d *commands.Driver) error { return d.DefineNVBitImage(2, 64, 0, 32, 0, data) })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := append([]byte{commands.FS, 'q', 2, 64, 0, 32, 0}, data...)
+	if !bytes.Equal(got, want) {
+		t.Errorf("sent %d bytes starting with % x, want % x", len(got), got[:min(len(got), 7)], want[:7])
+	}
+}
+
+func TestDefineNVBitImageErrors(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		n      uint8
+		xL, xH uint8
+		yL, yH uint8
+		d      []byte
+	}{
+		{"no image", 0, 1, 0, 1, 0, nvImage(true, 1, 1)},
+		{"empty image", 1, 0, 0, 1, 0, nil},
+		{"too wide", 1, 0, 4, 1, 0, nil},
+		{"too tall", 1, 1, 0, 33, 1, nil},
+		{"short data", 1, 2, 0, 2, 0, nvImage(true, 2, 1)},
+		{"extra data", 1, 1, 0, 1, 0, nvImage(true, 2, 1)},
+		{"missing header", 2, 1, 0, 1, 0, append(nvImage(true, 1, 1), 1, 0)},
+	} {
+		d := commands.NewBuffer()
+
+		err := d.DefineNVBitImage(tt.n, tt.xL, tt.xH, tt.yL, tt.yH, tt.d)
+		if !errors.Is(err, commands.ErrInvalidNVBitImage) {
+			t.Errorf("%s: got %v, want ErrInvalidNVBitImage", tt.name, err)
+		}
+
+		if len(d.Bytes()) != 0 {
+			t.Errorf("%s: sent % x", tt.name, d.Bytes())
+		}
+	}
+}
+
+// 257 x 32 x 8 bytes fit in the NV memory of the RP326, not in the 64K of
+// the RP325
+func TestDefineNVBitImageMemory(t *testing.T) {
+	image := nvImage(true, 257, 32)
+
+	for _, tt := range []struct {
+		model string
+		want  error
+	}{
+		{"RP325", commands.ErrNVMemoryExceeded},
+		{"RP326", nil},
+		{"", nil}, // Unchecked without a profile
+	} {
+		var opts []commands.Option
+		if tt.model != "" {
+			opts = append(opts, commands.WithProfile(lookup(t, tt.model)))
+		}
+
+		d := commands.NewDriver(nil, append(opts, commands.WithBuffering(0))...)
+		if err := d.DefineNVBitImage(1, 1, 1, 32, 0, image); !errors.Is(err, tt.want) {
+			t.Errorf("%q: got %v, want %v", tt.model, err, tt.want)
+		}
+	}
+}
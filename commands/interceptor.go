@@ -0,0 +1,123 @@
+package commands
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// A command going through the driver
+// Bytes and Response must not be modified. In buffered mode the command is
+// intercepted when it is added to the buffer.
+type Call struct {
+	Name     string // Driver method, e.g. "SetJustification"
+	Bytes    []byte // Encoded command
+	Duration time.Duration
+	Err      error
+	Response []byte // Reply of queries
+}
+
+// Hooks run around every command
+// Before gets the name and bytes, After gets the whole call. Either may be
+// nil. Hooks run while the command holds the connection and must not use
+// the driver.
+type Interceptor struct {
+	Before func(ctx context.Context, call *Call)
+	After  func(ctx context.Context, call *Call)
+}
+
+// Run interceptors around every command, in the order given
+func WithInterceptors(interceptors ...Interceptor) Option {
+	return func(p *Driver) {
+		p.interceptors = append(p.interceptors, interceptors...)
+	}
+}
+
+// Runs fn, sending command name, between the interceptors
+func (p *Driver) intercept(name string, b []byte, fn func() ([]byte, error)) ([]byte, error) {
+	if len(p.interceptors) == 0 {
+		return fn()
+	}
+
+	call := &Call{Name: name, Bytes: b}
+	for _, i := range p.interceptors {
+		if i.Before != nil {
+			i.Before(p.ctx, call)
+		}
+	}
+
+	start := time.Now()
+	call.Response, call.Err = fn()
+	call.Duration = time.Since(start)
+
+	for _, i := range p.interceptors {
+		if i.After != nil {
+			i.After(p.ctx, call)
+		}
+	}
+
+	return call.Response, call.Err
+}
+
+// Logs every command to logger
+// Successful commands are logged at debug level, failures at error level.
+func SlogInterceptor(logger *slog.Logger) Interceptor {
+	return Interceptor{
+		After: func(ctx context.Context, call *Call) {
+			level := slog.LevelDebug
+			attrs := []slog.Attr{
+				slog.String("command", call.Name),
+				slog.String("bytes", hexString(call.Bytes)),
+				slog.Duration("duration", call.Duration),
+			}
+
+			if call.Response != nil {
+				attrs = append(attrs, slog.String("response", hexString(call.Response)))
+			}
+
+			if call.Err != nil {
+				level = slog.LevelError
+				attrs = append(attrs, slog.Any("error", call.Err))
+			}
+
+			logger.LogAttrs(ctx, level, "printer command", attrs...)
+		},
+	}
+}
+
+// Counts commands in m
+// "calls" and "errors" hold per command counts, "bytes_sent",
+// "bytes_received" and "duration_ns" the totals. Publish m with
+// expvar.Publish, or create it with expvar.NewMap.
+func ExpvarInterceptor(m *expvar.Map) Interceptor {
+	calls := new(expvar.Map)
+	errs := new(expvar.Map)
+	m.Set("calls", calls)
+	m.Set("errors", errs)
+
+	return Interceptor{
+		After: func(ctx context.Context, call *Call) {
+			calls.Add(call.Name, 1)
+			if call.Err != nil {
+				errs.Add(call.Name, 1)
+			}
+
+			m.Add("bytes_sent", int64(len(call.Bytes)))
+			m.Add("bytes_received", int64(len(call.Response)))
+			m.Add("duration_ns", int64(call.Duration))
+		},
+	}
+}
+
+// Formats at most 64 bytes of b as hex
+func hexString(b []byte) string {
+	const max = 64
+
+	if len(b) > max {
+		return fmt.Sprintf("% x ... (%d bytes)", b[:max], len(b))
+	}
+
+	return fmt.Sprintf("% x", b)
+}
@@ -0,0 +1,153 @@
+package commands_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/cyb3rjerry/rongta-escpos/commands"
+)
+
+// Sends a command, a query answered with the cutter jammed and a query
+// left unanswered
+func interceptedSession(t *testing.T, interceptor commands.Interceptor) {
+	t.Helper()
+
+	d, conn := newMutedDriver(t, 0, commands.WithInterceptors(interceptor))
+	conn.muted.Store(false)
+
+	if err := d.Initialize(); err != nil {
+		t.Fatal(err)
+	}
+
+	conn.SetStatus(3, statusJammed)
+	if _, err := withTimeout(t, d, time.Second).GetAutocutterStatus(); err != nil {
+		t.Fatal(err)
+	}
+
+	conn.muted.Store(true)
+	_, err := withTimeout(t, d, 50*time.Millisecond).GetAutocutterStatus()
+	if !errors.Is(err, commands.ErrTimeout) {
+		t.Fatalf("got %v, want ErrTimeout", err)
+	}
+}
+
+func TestSlogInterceptor(t *testing.T) {
+	var out bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&out, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	interceptedSession(t, commands.SlogInterceptor(logger))
+
+	var records []map[string]any
+	for dec := json.NewDecoder(&out); dec.More(); {
+		var record map[string]any
+		if err := dec.Decode(&record); err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, record)
+	}
+
+	if len(records) != 3 {
+		t.Fatalf("got %d records, want 3", len(records))
+	}
+
+	for i, want := range []map[string]any{
+		{"level": "DEBUG", "command": "Initialize", "bytes": "1b 40"},
+		{"level": "DEBUG", "command": "TransmitRealTimeStatus", "bytes": "10 04 03", "response": "1a"},
+		{"level": "ERROR", "command": "TransmitRealTimeStatus", "bytes": "10 04 03"},
+	} {
+		record := records[i]
+		for key, value := range want {
+			if record[key] != value {
+				t.Errorf("record %d: %s is %v, want %v", i, key, record[key], value)
+			}
+		}
+
+		if record["msg"] != "printer command" {
+			t.Errorf("record %d: message %v", i, record["msg"])
+		}
+
+		if _, ok := record["duration"]; !ok {
+			t.Errorf("record %d: no duration", i)
+		}
+	}
+
+	if _, ok := records[0]["response"]; ok {
+		t.Error("response logged for a command without reply")
+	}
+
+	if _, ok := records[2]["error"]; !ok {
+		t.Error("error of the failed query not logged")
+	}
+}
+
+func TestExpvarInterceptor(t *testing.T) {
+	m := new(expvar.Map)
+
+	interceptedSession(t, commands.ExpvarInterceptor(m))
+
+	calls := m.Get("calls").(*expvar.Map)
+	errs := m.Get("errors").(*expvar.Map)
+
+	for _, tt := range []struct {
+		vars *expvar.Map
+		key  string
+		want string
+	}{
+		{calls, "Initialize", "1"},
+		{calls, "TransmitRealTimeStatus", "2"},
+		{errs, "TransmitRealTimeStatus", "1"},
+		{m, "bytes_sent", "8"},
+		{m, "bytes_received", "1"},
+	} {
+		v := tt.vars.Get(tt.key)
+		if v == nil || v.String() != tt.want {
+			t.Errorf("%s: got %v, want %s", tt.key, v, tt.want)
+		}
+	}
+
+	if errs.Get("Initialize") != nil {
+		t.Error("Initialize counted as an error")
+	}
+
+	if d := m.Get("duration_ns"); d == nil || d.String() == "0" {
+		t.Errorf("duration_ns is %v", d)
+	}
+}
+
+// Before hooks run in order, then the command, then the After hooks
+func TestInterceptorOrder(t *testing.T) {
+	var order []string
+	hook := func(name string) commands.Interceptor {
+		return commands.Interceptor{
+			Before: func(ctx context.Context, call *commands.Call) {
+				order = append(order, "before "+name+" "+call.Name)
+			},
+			After: func(ctx context.Context, call *commands.Call) {
+				order = append(order, "after "+name+" "+call.Name)
+			},
+		}
+	}
+
+	d, _ := newDriver(t, commands.WithInterceptors(hook("a"), hook("b")))
+	if err := d.Initialize(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"before a Initialize", "before b Initialize", "after a Initialize", "after b Initialize"}
+	if len(order) != len(want) {
+		t.Fatalf("got %v, want %v", order, want)
+	}
+
+	for i := range want {
+		if order[i] != want[i] {
+			t.Errorf("got %v, want %v", order, want)
+			break
+		}
+	}
+}
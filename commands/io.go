@@ -0,0 +1,314 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"os"
+	"time"
+)
+
+var (
+	ErrTimeout = errors.New("printer did not respond in time")
+)
+
+// Returned when the context deadline expires during a read or write
+// errors.Is matches both ErrTimeout and context.DeadlineExceeded
+type TimeoutError struct {
+	Op  string // "lock", "read" or "write"
+	Err error
+}
+
+func (e *TimeoutError) Error() string {
+	return e.Op + ": " + ErrTimeout.Error()
+}
+
+func (e *TimeoutError) Unwrap() []error {
+	return []error{ErrTimeout, e.Err}
+}
+
+// Implements the net.Error timeout convention
+func (e *TimeoutError) Timeout() bool {
+	return true
+}
+
+// Implemented by net.Conn and pollable *os.File
+type deadliner interface {
+	SetReadDeadline(t time.Time) error
+	SetWriteDeadline(t time.Time) error
+}
+
+// Runs fn with exclusive access to the connection
+// The whole receipt goes out without bytes from other goroutines in
+// between, and replies can't be stolen by another caller. Other callers,
+// including real-time status queries, get their turn once the job returns.
+// Commands sent through the job's driver don't wait for the lock, the
+// driver must not be used once fn has returned.
+func (p *Driver) Job(fn func(*Driver) error) error {
+	if p.locked {
+		return fn(p)
+	}
+
+	if err := p.lock(); err != nil {
+		return commandError("Job", err)
+	}
+	defer p.unlock()
+
+	return fn(&Driver{conn: p.conn, ctx: p.ctx, locked: true})
+}
+
+// Waits for the connection, giving up when the context ends
+func (p *Driver) lock() error {
+	select {
+	case p.sem <- struct{}{}:
+		return nil
+	case <-p.ctx.Done():
+		return contextError("lock", p.ctx.Err())
+	}
+}
+
+// Takes the connection only if nobody holds it
+func (p *Driver) tryLock() bool {
+	select {
+	case p.sem <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+func (p *Driver) unlock() {
+	<-p.sem
+}
+
+// Runs fn with exclusive access, unless the view already holds it
+func (p *Driver) exclusive(fn func() error) error {
+	if p.locked {
+		return fn()
+	}
+
+	if err := p.lock(); err != nil {
+		return err
+	}
+	defer p.unlock()
+
+	return fn()
+}
+
+// Sends command name to the printer, or to the buffer in buffered mode
+func (p *Driver) exec(name string, b []byte) error {
+	return p.exclusive(func() error {
+		return p.command(name, b)
+	})
+}
+
+// Sends command name, the caller has exclusive access
+func (p *Driver) command(name string, b []byte) error {
+	_, err := p.intercept(name, b, func() ([]byte, error) {
+		return nil, commandError(name, p.send(b))
+	})
+
+	return err
+}
+
+// Sends a request and reads its reply without letting another command in
+// between
+func (p *Driver) query(name string, req []byte, complete frame) ([]byte, error) {
+	var reply []byte
+
+	err := p.exclusive(func() error {
+		var err error
+
+		reply, err = p.intercept(name, req, func() ([]byte, error) {
+			reply, err := p.request(req, complete)
+			return reply, commandError(name, err)
+		})
+
+		return err
+	})
+
+	return reply, commandError(name, err)
+}
+
+func (p *Driver) send(b []byte) error {
+	if p.isClosed() {
+		return ErrClosed
+	}
+
+	if p.buffered {
+		p.buf.Write(b)
+
+		if p.chunkSize > 0 && p.buf.Len() >= p.chunkSize {
+			return p.flush(false)
+		}
+
+		return nil
+	}
+
+	if p.rwc == nil {
+		return ErrNotConnected
+	}
+
+	if realTime(b) {
+		_, err := p.writeOnce(b)
+		return err
+	}
+
+	_, err := p.rawWrite(b)
+	return err
+}
+
+// Real-time commands are processed as soon as they are received, pacing
+// them would only delay them
+func realTime(b []byte) bool {
+	if len(b) < 2 || b[0] != DLE {
+		return false
+	}
+
+	return b[1] == EOT || b[1] == ENQ || b[1] == DC4
+}
+
+// Writes b to the connection, paced when WithPacing is set
+func (p *Driver) rawWrite(b []byte) (int, error) {
+	if p.pacing != nil {
+		return p.pacedWrite(b)
+	}
+
+	return p.writeOnce(b)
+}
+
+func (p *Driver) writeOnce(b []byte) (int, error) {
+	return p.do("write", func(d deadliner) func(time.Time) error {
+		return d.SetWriteDeadline
+	}, func() (int, error) {
+		return p.rwc.Write(b)
+	})
+}
+
+// Sends req straight to the printer and waits for its reply, the commands
+// queued before it in buffered mode are flushed first
+func (p *Driver) request(req []byte, complete frame) ([]byte, error) {
+	if p.isClosed() {
+		return nil, ErrClosed
+	}
+
+	if err := p.flush(true); err != nil {
+		return nil, err
+	}
+
+	if p.rwc == nil {
+		return nil, ErrNotConnected
+	}
+
+	// Unpaced, a busy poll would take the reply
+	return p.roundTrip(req, complete, p.writeOnce)
+}
+
+// Writes req with write and waits for the reply
+// The reply is expected before the request goes out, a fast printer could
+// otherwise answer before anyone waits for it.
+func (p *Driver) roundTrip(req []byte, complete frame, write func([]byte) (int, error)) ([]byte, error) {
+	w, err := p.expect(complete)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := write(req); err != nil {
+		p.forget(w)
+		return nil, err
+	}
+
+	return p.wait(w)
+}
+
+// Runs a write under the driver's context
+// Connections supporting deadlines get the context deadline, and are
+// interrupted on cancellation by moving it to the past. Other connections
+// run the operation in a goroutine that is abandoned when the context ends,
+// the next write then waits for it to return so that the bytes of two
+// commands don't interleave. The caller has exclusive access.
+func (p *Driver) do(op string, deadline func(deadliner) func(time.Time) error, fn func() (int, error)) (int, error) {
+	if err := p.awaitAbandoned(op); err != nil {
+		return 0, err
+	}
+
+	ctx := p.ctx
+	if ctx.Done() == nil {
+		return fn()
+	}
+
+	if err := ctx.Err(); err != nil {
+		return 0, contextError(op, err)
+	}
+
+	if d, ok := p.rwc.(deadliner); ok {
+		setDeadline := deadline(d)
+		t, _ := ctx.Deadline()
+
+		if err := setDeadline(t); err == nil {
+			interrupted := make(chan struct{})
+			stop := context.AfterFunc(ctx, func() {
+				setDeadline(time.Unix(1, 0))
+				close(interrupted)
+			})
+
+			n, err := fn()
+
+			// A late interruption would outlive the reset
+			if !stop() {
+				<-interrupted
+			}
+			setDeadline(time.Time{})
+
+			if err != nil && (ctx.Err() != nil || errors.Is(err, os.ErrDeadlineExceeded)) {
+				return n, contextError(op, context.Cause(ctx))
+			}
+
+			return n, err
+		}
+	}
+
+	type result struct {
+		n   int
+		err error
+	}
+
+	done := make(chan result, 1)
+	returned := make(chan struct{})
+	go func() {
+		n, err := fn()
+		done <- result{n, err}
+		close(returned)
+	}()
+
+	select {
+	case r := <-done:
+		return r.n, r.err
+	case <-ctx.Done():
+		p.abandoned = returned
+		return 0, contextError(op, ctx.Err())
+	}
+}
+
+// Waits for the write an earlier context abandoned, see do
+func (p *Driver) awaitAbandoned(op string) error {
+	if p.abandoned == nil {
+		return nil
+	}
+
+	select {
+	case <-p.abandoned:
+		p.abandoned = nil
+		return nil
+	case <-p.ctx.Done():
+		return contextError(op, p.ctx.Err())
+	}
+}
+
+// Deadlines become a TimeoutError, cancellations are returned as is
+func contextError(op string, err error) error {
+	if err == nil || errors.Is(err, context.DeadlineExceeded) {
+		return &TimeoutError{Op: op, Err: context.DeadlineExceeded}
+	}
+
+	return err
+}
@@ -0,0 +1,125 @@
+package commands_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cyb3rjerry/rongta-escpos/commands"
+)
+
+// Connection without deadlines whose writes block until released
+type stuckConn struct {
+	mu      sync.Mutex
+	writes  int
+	writing int
+	overlap bool
+	written []byte
+
+	release chan struct{}
+	closed  chan struct{}
+}
+
+func newStuckConn() *stuckConn {
+	return &stuckConn{release: make(chan struct{}), closed: make(chan struct{})}
+}
+
+func (c *stuckConn) Write(b []byte) (int, error) {
+	c.mu.Lock()
+	c.writes++
+	c.writing++
+	c.overlap = c.overlap || c.writing > 1
+	c.mu.Unlock()
+
+	<-c.release
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.writing--
+	c.written = append(c.written, b...)
+
+	return len(b), nil
+}
+
+func (c *stuckConn) Read(b []byte) (int, error) {
+	<-c.closed
+	return 0, io.EOF
+}
+
+func (c *stuckConn) Close() error {
+	close(c.closed)
+	return nil
+}
+
+func (c *stuckConn) calls() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.writes
+}
+
+func TestAbandonedWriteIsWaitedFor(t *testing.T) {
+	conn := newStuckConn()
+	d := commands.NewDriver(conn)
+	defer d.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	err := d.WithContext(ctx).SetEmphasizedMode(1)
+	if !errors.Is(err, commands.ErrTimeout) {
+		t.Fatalf("got %v, want ErrTimeout", err)
+	}
+
+	result := make(chan error, 1)
+	go func() {
+		result <- d.SetDoubleStrikeMode(1)
+	}()
+
+	// The next command waits for the abandoned write
+	time.Sleep(50 * time.Millisecond)
+	if n := conn.calls(); n != 1 {
+		t.Fatalf("%d writes started, want only the abandoned one", n)
+	}
+
+	close(conn.release)
+	if err := <-result; err != nil {
+		t.Fatal(err)
+	}
+
+	if conn.overlap {
+		t.Error("writes overlapped")
+	}
+
+	want := []byte{commands.ESC, 'E', 1, commands.ESC, 'G', 1}
+	if !bytes.Equal(conn.written, want) {
+		t.Errorf("wrote % x, want % x", conn.written, want)
+	}
+}
+
+func TestAbandonedWriteWaitHonorsContext(t *testing.T) {
+	conn := newStuckConn()
+	defer close(conn.release)
+
+	d := commands.NewDriver(conn)
+	defer d.Close()
+
+	for i := 0; i < 2; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		err := d.WithContext(ctx).SetEmphasizedMode(1)
+		cancel()
+
+		if !errors.Is(err, commands.ErrTimeout) {
+			t.Fatalf("command %d: got %v, want ErrTimeout", i, err)
+		}
+	}
+
+	if n := conn.calls(); n != 1 {
+		t.Errorf("%d writes started, want 1", n)
+	}
+}
@@ -0,0 +1,97 @@
+package commands_test
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/cyb3rjerry/rongta-escpos/commands"
+	"github.com/cyb3rjerry/rongta-escpos/rongtasim"
+)
+
+// Initialize a driver on a virtual printer, closed when the test ends
+func newDriver(t *testing.T, opts ...commands.Option) (*commands.Driver, *rongtasim.Printer) {
+	t.Helper()
+
+	sim := rongtasim.New()
+	t.Cleanup(func() { sim.Close() })
+
+	return commands.NewDriver(sim, opts...), sim
+}
+
+// Run with -race, the jobs write one line at a time while other goroutines
+// send commands of their own
+func TestJobsDontInterleave(t *testing.T) {
+	const jobs, lines = 8, 20
+
+	d, sim := newDriver(t)
+
+	var wg sync.WaitGroup
+	for n := range jobs {
+		wg.Add(2)
+
+		go func() {
+			defer wg.Done()
+
+			err := d.Job(func(job *commands.Driver) error {
+				for i := range lines {
+					if err := job.WriteStringToBuffer(fmt.Sprintf("<%d:%d>", n, i)); err != nil {
+						return err
+					}
+				}
+
+				return nil
+			})
+			if err != nil {
+				t.Error(err)
+			}
+		}()
+
+		go func() {
+			defer wg.Done()
+
+			for range lines {
+				if err := d.WriteStringToBuffer("|"); err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	written := string(sim.Raw())
+	for n := range jobs {
+		var job strings.Builder
+		for i := range lines {
+			fmt.Fprintf(&job, "<%d:%d>", n, i)
+		}
+
+		if !strings.Contains(written, job.String()) {
+			t.Errorf("job %d was interleaved: %s", n, written)
+		}
+	}
+
+	if got := strings.Count(written, "|"); got != jobs*lines {
+		t.Errorf("%d commands outside the jobs, want %d", got, jobs*lines)
+	}
+}
+
+// A nested Job reuses the lock instead of waiting for itself
+func TestNestedJob(t *testing.T) {
+	d, sim := newDriver(t)
+
+	err := d.Job(func(job *commands.Driver) error {
+		return job.Job(func(inner *commands.Driver) error {
+			return inner.Initialize()
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if w := sim.Raw(); !bytes.Equal(w, []byte{commands.ESC, '@'}) {
+		t.Errorf("wrote % x, want ESC @", w)
+	}
+}
@@ -0,0 +1,185 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Polls the printer status with DLE EOT 1 to 4, for printers whose ASB
+// can't be relied on
+// A new status is reported once it has been read Debounce times in a row,
+// and callbacks only fire when it changes. The first status is compared
+// with an idle printer, so conditions already present are reported once.
+// Callbacks run on the monitor goroutine.
+type StatusMonitor struct {
+	Interval time.Duration // Delay between two polls, defaults to 1s
+	Timeout  time.Duration // Time allowed for a poll, defaults to Interval
+	Debounce int           // Identical polls needed to report a change, defaults to 2
+
+	OnChange       func(StatusChange) // Every event, see statusEvents
+	OnPaperLow     func(Status)
+	OnPaperOut     func(Status)
+	OnCoverOpen    func(Status)
+	OnError        func(Status)
+	OnRecovered    func(Status) // Back online, cover closed, no error and paper loaded
+	OnDisconnected func(error)  // Debounce polls in a row failed
+}
+
+func (m *StatusMonitor) Default() {
+	m.Interval = time.Second
+	m.Timeout = m.Interval
+	m.Debounce = 2
+}
+
+// Starts polling the printer with m
+// Polls are skipped while another caller holds the connection, so they
+// never end up between the bytes of a job. Polling stops when the driver
+// or its context is closed, or with the returned stop function, which
+// waits for the last callback to return and must not be called from one.
+func (p *Driver) Monitor(m StatusMonitor) func() {
+	defaults := StatusMonitor{}
+	defaults.Default()
+
+	if m.Interval <= 0 {
+		m.Interval = defaults.Interval
+	}
+
+	if m.Timeout <= 0 {
+		m.Timeout = m.Interval
+	}
+
+	if m.Debounce <= 0 {
+		m.Debounce = defaults.Debounce
+	}
+
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+		m.run(p, stop)
+	}()
+
+	return func() {
+		select {
+		case <-stop:
+		default:
+			close(stop)
+		}
+
+		<-stopped
+	}
+}
+
+func (m *StatusMonitor) run(p *Driver, stop <-chan struct{}) {
+	ticker := time.NewTicker(m.Interval)
+	defer ticker.Stop()
+
+	var (
+		reported     Status // Last status passed to the callbacks
+		candidate    Status // Status waiting to be confirmed
+		seen         int    // Polls in a row that read candidate
+		failures     int    // Polls in a row that failed
+		disconnected bool
+	)
+
+	for {
+		status, err := m.poll(p)
+
+		switch {
+		case errors.Is(err, ErrClosed):
+			return
+		case errors.Is(err, errSkipped), errors.Is(err, ErrProtocol):
+			// Busy connection or line noise, try again on the next tick
+		case err != nil:
+			seen = 0
+			failures++
+
+			if failures == m.Debounce && !disconnected {
+				disconnected = true
+				if m.OnDisconnected != nil {
+					m.OnDisconnected(err)
+				}
+			}
+		default:
+			failures = 0
+
+			if seen == 0 || status != candidate {
+				candidate, seen = status, 0
+			}
+			seen++
+
+			if seen == m.Debounce && (status != reported || disconnected) {
+				m.report(reported, status, disconnected)
+				reported, disconnected = status, false
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		case <-p.done:
+			return
+		case <-p.ctx.Done():
+			return
+		}
+	}
+}
+
+var errSkipped = errors.New("connection busy")
+
+// Reads the status, unless someone else holds the connection
+func (m *StatusMonitor) poll(p *Driver) (Status, error) {
+	if p.isClosed() {
+		return Status{}, ErrClosed
+	}
+
+	if !p.tryLock() {
+		return Status{}, errSkipped
+	}
+	defer p.unlock()
+
+	ctx, cancel := context.WithTimeout(p.ctx, m.Timeout)
+	defer cancel()
+
+	return (&Driver{conn: p.conn, ctx: ctx, locked: true}).status()
+}
+
+// Fires the callbacks for the change from prev to next
+func (m *StatusMonitor) report(prev, next Status, reconnected bool) {
+	for _, e := range statusEvents(prev, next) {
+		if m.OnChange != nil {
+			m.OnChange(StatusChange{Event: e, Status: next})
+		}
+
+		var callback func(Status)
+
+		switch e {
+		case EventPaperNearEnd:
+			callback = m.OnPaperLow
+		case EventPaperOut:
+			callback = m.OnPaperOut
+		case EventCoverOpened:
+			callback = m.OnCoverOpen
+		}
+
+		if callback != nil {
+			callback(next)
+		}
+	}
+
+	if !prev.failed() && next.failed() && m.OnError != nil {
+		m.OnError(next)
+	}
+
+	if (reconnected || !prev.ready()) && next.ready() && m.OnRecovered != nil {
+		m.OnRecovered(next)
+	}
+}
+
+// Reports whether the printer can print
+func (s Status) ready() bool {
+	return !s.Offline && !s.CoverOpen && !s.failed() && !s.PaperEnd
+}
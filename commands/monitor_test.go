@@ -0,0 +1,187 @@
+package commands_test
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cyb3rjerry/rongta-escpos/commands"
+	"github.com/cyb3rjerry/rongta-escpos/commands/commandstest"
+)
+
+// Queues the DLE EOT 1 to 4 replies of a poll
+func replyStatus(conn *commandstest.Conn, coverOpen, paperLow bool) {
+	offline, paper := statusOK, statusOK
+	if coverOpen {
+		offline |= commands.COVER_STATUS_MASK
+	}
+
+	if paperLow {
+		paper |= commands.PAPER_NEAR_END_STATUS_MASK
+	}
+
+	for _, reply := range []byte{statusOK, offline, statusOK, paper} {
+		conn.Reply("TransmitRealTimeStatus", reply)
+	}
+}
+
+// Collects the monitor callbacks
+type monitorLog struct {
+	mu     sync.Mutex
+	events []string
+	polls  []int // Commands written when each callback fired
+	fired  chan struct{}
+}
+
+func newMonitorLog() *monitorLog {
+	return &monitorLog{fired: make(chan struct{}, 16)}
+}
+
+func (l *monitorLog) record(conn *commandstest.Conn, event string) {
+	l.mu.Lock()
+	l.events = append(l.events, event)
+	l.polls = append(l.polls, len(conn.Commands()))
+	l.mu.Unlock()
+
+	l.fired <- struct{}{}
+}
+
+func (l *monitorLog) wait(t *testing.T) {
+	t.Helper()
+
+	select {
+	case <-l.fired:
+	case <-time.After(2 * time.Second):
+		t.Fatal("callback not fired")
+	}
+}
+
+func TestMonitorDebouncesStatus(t *testing.T) {
+	d, conn := commandstest.NewDriver(t)
+	log := newMonitorLog()
+
+	// Idle twice, flapping, then open for good
+	for _, open := range []bool{false, false, true, false, true, true} {
+		replyStatus(conn, open, false)
+	}
+
+	stop := d.Monitor(commands.StatusMonitor{
+		Interval: 5 * time.Millisecond,
+		Timeout:  time.Second,
+		Debounce: 2,
+		OnChange: func(c commands.StatusChange) {
+			log.record(conn, c.Event.String())
+		},
+		OnCoverOpen: func(commands.Status) {
+			log.record(conn, "cover open")
+		},
+	})
+
+	log.wait(t)
+	log.wait(t)
+	stop()
+
+	log.mu.Lock()
+	defer log.mu.Unlock()
+
+	if len(log.events) != 2 || log.events[0] != commands.EventCoverOpened.String() || log.events[1] != "cover open" {
+		t.Errorf("got %v, want the cover opening once", log.events)
+	}
+
+	// Reported once the sixth poll confirmed it
+	if log.polls[0] != 6*4 {
+		t.Errorf("reported after %d requests, want 24", log.polls[0])
+	}
+}
+
+func TestMonitorSkipsPollsDuringJob(t *testing.T) {
+	d, conn := commandstest.NewDriver(t)
+	log := newMonitorLog()
+
+	replyStatus(conn, false, true)
+	replyStatus(conn, false, true)
+
+	var stop func()
+	err := d.Job(func(job *commands.Driver) error {
+		stop = d.Monitor(commands.StatusMonitor{
+			Interval: 5 * time.Millisecond,
+			OnPaperLow: func(commands.Status) {
+				log.record(conn, "paper low")
+			},
+		})
+
+		time.Sleep(50 * time.Millisecond)
+		if n := len(conn.Commands()); n != 0 {
+			t.Errorf("%d requests written while the job held the connection", n)
+		}
+
+		return job.Initialize()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Polling resumes once the job is over
+	log.wait(t)
+	stop()
+
+	cmds := conn.Commands()
+	if len(cmds) != 1+2*4 || cmds[0].Name != "Initialize" {
+		t.Errorf("got %v, want the job then two polls", cmds)
+	}
+}
+
+func TestMonitorReportsDisconnectionAndRecovery(t *testing.T) {
+	defer func(d time.Duration) { *commands.LateReplyTimeout = d }(*commands.LateReplyTimeout)
+	*commands.LateReplyTimeout = time.Millisecond
+
+	d, conn := commandstest.NewDriver(t)
+	log := newMonitorLog()
+
+	var (
+		mu    sync.Mutex
+		cause error
+	)
+
+	// Nothing answers the first polls
+	stop := d.Monitor(commands.StatusMonitor{
+		Interval: 20 * time.Millisecond,
+		Timeout:  10 * time.Millisecond,
+		Debounce: 2,
+		OnDisconnected: func(err error) {
+			mu.Lock()
+			cause = err
+			mu.Unlock()
+
+			log.record(conn, "disconnected")
+		},
+		OnRecovered: func(commands.Status) {
+			log.record(conn, "recovered")
+		},
+	})
+	defer stop()
+
+	log.wait(t)
+
+	mu.Lock()
+	if !errors.Is(cause, commands.ErrTimeout) {
+		t.Errorf("disconnected with %v, want ErrTimeout", cause)
+	}
+	mu.Unlock()
+
+	// The printer answers again
+	for i := 0; i < 4; i++ {
+		replyStatus(conn, false, false)
+	}
+
+	log.wait(t)
+	stop()
+
+	log.mu.Lock()
+	defer log.mu.Unlock()
+
+	if len(log.events) != 2 || log.events[0] != "disconnected" || log.events[1] != "recovered" {
+		t.Errorf("got %v, want a disconnection then the recovery", log.events)
+	}
+}
@@ -0,0 +1,124 @@
+package commands
+
+import (
+	"fmt"
+	"time"
+)
+
+// Limits how fast data reaches the printer
+// Writes are split into chunks of ChunkSize bytes. After each chunk the
+// driver waits for the time the serial link needs to carry it at BaudRate
+// (10 bits per byte), and between chunks it can poll DLE EOT 1 until the
+// printer is back online, so that its receive buffer doesn't overrun. A
+// printer still offline after MaxBusyWait fails the write with ErrNotReady.
+// Real-time commands and the requests of status queries aren't paced.
+type Pacing struct {
+	ChunkSize    int           // Defaults to 256
+	BaudRate     int           // 0 disables the baud budget
+	PollBusy     bool          // Wait for the printer to be online between chunks
+	PollInterval time.Duration // Delay between two busy polls, defaults to 50ms
+	MaxBusyWait  time.Duration // Longest wait for the printer to be online, defaults to 30s
+}
+
+func (p *Pacing) Default() {
+	p.ChunkSize = 256
+	p.PollInterval = 50 * time.Millisecond
+	p.MaxBusyWait = 30 * time.Second
+}
+
+// Pace writes to the printer, see Pacing
+func WithPacing(pacing Pacing) Option {
+	return func(p *Driver) {
+		defaults := Pacing{}
+		defaults.Default()
+
+		if pacing.ChunkSize <= 0 {
+			pacing.ChunkSize = defaults.ChunkSize
+		}
+
+		if pacing.PollInterval <= 0 {
+			pacing.PollInterval = defaults.PollInterval
+		}
+
+		if pacing.MaxBusyWait <= 0 {
+			pacing.MaxBusyWait = defaults.MaxBusyWait
+		}
+
+		p.pacing = &pacing
+	}
+}
+
+// Writes b in paced chunks, returns the number of bytes written
+func (p *Driver) pacedWrite(b []byte) (int, error) {
+	written := 0
+
+	for len(b) > 0 {
+		chunk := b
+		if len(chunk) > p.pacing.ChunkSize {
+			chunk = chunk[:p.pacing.ChunkSize]
+		}
+
+		n, err := p.writeOnce(chunk)
+		written += n
+		if err != nil {
+			return written, err
+		}
+		b = b[n:]
+
+		if err := p.pace(n, len(b) > 0); err != nil {
+			return written, err
+		}
+	}
+
+	return written, nil
+}
+
+// Waits until the printer is ready for more than the n bytes just written
+// The printer is only polled when more chunks follow.
+func (p *Driver) pace(n int, more bool) error {
+	if p.pacing.BaudRate > 0 {
+		budget := time.Duration(n) * 10 * time.Second / time.Duration(p.pacing.BaudRate)
+		if err := p.sleep(budget); err != nil {
+			return err
+		}
+	}
+
+	if !p.pacing.PollBusy || !more {
+		return nil
+	}
+
+	deadline := time.Now().Add(p.pacing.MaxBusyWait)
+
+	for {
+		reply, err := p.roundTrip([]byte{DLE, EOT, 0x01}, fixedFrame(1), p.writeOnce)
+		if err != nil {
+			return err
+		}
+
+		if IsRealTimeStatus(reply[0]) && reply[0]&OFFLINE_STATUS_MASK == 0 {
+			return nil
+		}
+
+		// An open cover or an empty roll keeps the printer offline
+		if time.Now().After(deadline) {
+			return fmt.Errorf("%w: offline for more than %s", ErrNotReady, p.pacing.MaxBusyWait)
+		}
+
+		if err := p.sleep(p.pacing.PollInterval); err != nil {
+			return err
+		}
+	}
+}
+
+// Waits for d, giving up when the context ends
+func (p *Driver) sleep(d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-p.ctx.Done():
+		return contextError("write", p.ctx.Err())
+	}
+}
@@ -0,0 +1,109 @@
+package commands_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cyb3rjerry/rongta-escpos/commands"
+	"github.com/cyb3rjerry/rongta-escpos/commands/commandstest"
+)
+
+func TestPacedQueriesAreNotPolled(t *testing.T) {
+	d, conn := commandstest.NewDriver(t, commands.WithPacing(commands.Pacing{PollBusy: true}))
+
+	conn.Reply("TransmitRealTimeStatus", statusJammed)
+	conn.Reply("TransmitRealTimeStatus", statusOK)
+
+	jammed, err := withTimeout(t, d, time.Second).GetAutocutterStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !jammed {
+		t.Error("autocutter reported ok")
+	}
+
+	failed, err := withTimeout(t, d, time.Second).GetUnrecoverableErrorStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if failed {
+		t.Error("unrecoverable error reported")
+	}
+
+	if n := len(conn.Commands()); n != 2 {
+		t.Errorf("%d commands written, want the 2 status requests", n)
+	}
+}
+
+func TestPacingPollsBetweenChunks(t *testing.T) {
+	d, conn := commandstest.NewDriver(t, commands.WithPacing(commands.Pacing{
+		ChunkSize: 256,
+		PollBusy:  true,
+	}))
+
+	// Busy once, then online
+	conn.Reply("TransmitRealTimeStatus", statusOK|commands.OFFLINE_STATUS_MASK)
+	conn.Reply("TransmitRealTimeStatus", statusOK)
+	conn.Reply("TransmitRealTimeStatus", statusOK)
+
+	text := strings.Repeat("x", 600)
+	if err := withTimeout(t, d, time.Second).WriteStringToBuffer(text); err != nil {
+		t.Fatal(err)
+	}
+
+	conn.ExpectCommand(t, "Text", strings.Repeat("x", 256))
+	conn.ExpectCommand(t, "TransmitRealTimeStatus", uint8(1))
+	conn.ExpectCommand(t, "TransmitRealTimeStatus", uint8(1))
+	conn.ExpectCommand(t, "Text", strings.Repeat("x", 256))
+	conn.ExpectCommand(t, "TransmitRealTimeStatus", uint8(1))
+	conn.ExpectCommand(t, "Text", strings.Repeat("x", 88))
+	conn.ExpectNoMoreCommands(t)
+}
+
+func TestPacingBaudBudget(t *testing.T) {
+	d, _ := commandstest.NewDriver(t, commands.WithPacing(commands.Pacing{BaudRate: 115200}))
+
+	start := time.Now()
+	if err := d.WriteStringToBuffer(strings.Repeat("x", 2000)); err != nil {
+		t.Fatal(err)
+	}
+
+	// 2000 bytes of 10 bits at 115200 baud
+	if elapsed := time.Since(start); elapsed < 170*time.Millisecond {
+		t.Errorf("written in %s, want at least 170ms", elapsed)
+	}
+}
+
+func TestPacingGivesUpOnOfflinePrinter(t *testing.T) {
+	d, conn := commandstest.NewDriver(t, commands.WithPacing(commands.Pacing{
+		ChunkSize:    256,
+		PollBusy:     true,
+		PollInterval: 10 * time.Millisecond,
+		MaxBusyWait:  50 * time.Millisecond,
+	}))
+
+	// The cover stays open
+	for i := 0; i < 100; i++ {
+		conn.Reply("TransmitRealTimeStatus", statusOK|commands.OFFLINE_STATUS_MASK)
+	}
+
+	err := withTimeout(t, d, time.Second).WriteStringToBuffer(strings.Repeat("x", 600))
+	if !errors.Is(err, commands.ErrNotReady) || !errors.Is(err, commands.ErrPrinterState) {
+		t.Fatalf("got %v, want ErrNotReady and ErrPrinterState", err)
+	}
+
+	cmds := conn.Commands()
+	if len(cmds) < 2 || cmds[0].Name != "Text" || len(cmds[0].Args[0].(string)) != 256 {
+		t.Fatalf("got %v, want the first chunk then polls", cmds)
+	}
+
+	for _, cmd := range cmds[1:] {
+		if cmd.Name != "TransmitRealTimeStatus" {
+			t.Errorf("got %v after the printer went offline, want only polls", cmd)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package commands
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+)
+
+var (
+	ErrUnsupportedCommand = errors.New("command not supported by the printer model")
+	ErrInvalidProfile     = errors.New("invalid printer profile")
+)
+
+// Capabilities of a printer model
+// A driver with a profile rejects the commands the model doesn't support
+// before sending them. Without a profile nothing is checked.
+type Profile struct {
+	Model         string
+	PaperWidth    Millimetres // Roll width, 58 or 80
+	PrintableDots Dots        // Dots per line, defaults from the paper width
+	Cutter        bool
+	PageMode      bool
+	Beeper        bool            // ESC B
+	NVMemory      int             // NV bit image area in bytes, unchecked when 0
+	CodePages     []CharacterCode // Tables accepted by ESC t, all when empty
+}
+
+var (
+	profilesMu sync.RWMutex
+	profiles   = map[string]Profile{}
+)
+
+// Code tables of the 58 mm firmware, the 80 mm models take every table of
+// ESC t
+var narrowCodePages = []CharacterCode{CP437, Katakana, CP850, CP860, CP863, CP865, WCP1252, CP866, CP852, CP858}
+
+// Built-in models, units with other options are described through
+// RegisterProfile
+func init() {
+	for _, profile := range []Profile{
+		// 58 mm printer with a tear bar, standard mode only
+		{Model: "RP325", PaperWidth: 58, NVMemory: 64 * 1024, CodePages: narrowCodePages},
+		{Model: "RP326", PaperWidth: 80, Cutter: true, PageMode: true, NVMemory: 192 * 1024},
+		// RP326 with a buzzer for ESC B
+		{Model: "RP327", PaperWidth: 80, Cutter: true, PageMode: true, Beeper: true, NVMemory: 192 * 1024},
+		// RP327 with a larger NV memory
+		{Model: "RP328", PaperWidth: 80, Cutter: true, PageMode: true, Beeper: true, NVMemory: 256 * 1024},
+	} {
+		profile.Default()
+		profiles[profile.Model] = profile
+	}
+}
+
+// Fills the settings left unset with values derived from the others
+func (p *Profile) Default() {
+	if p.PaperWidth == 0 {
+		p.PaperWidth = 80
+	}
+
+	if p.PrintableDots == 0 {
+		// 203 dpi print head, leaving a margin on each side of the roll
+		if p.PaperWidth < 80 {
+			p.PrintableDots = 384
+		} else {
+			p.PrintableDots = 576
+		}
+	}
+}
+
+// Whether ESC t accepts the code table
+func (p *Profile) SupportsCodePage(n CharacterCode) bool {
+	return len(p.CodePages) == 0 || slices.Contains(p.CodePages, n)
+}
+
+// Adds a profile, or replaces the one with the same model name
+// Unset settings are filled through Default.
+func RegisterProfile(profile Profile) error {
+	if profile.Model == "" {
+		return ErrInvalidProfile
+	}
+
+	profile.Default()
+	profile.CodePages = slices.Clone(profile.CodePages)
+
+	profilesMu.Lock()
+	defer profilesMu.Unlock()
+
+	profiles[strings.ToUpper(profile.Model)] = profile
+
+	return nil
+}
+
+// Returns a copy of the profile registered for model, ignoring case
+func LookupProfile(model string) (*Profile, bool) {
+	profilesMu.RLock()
+	defer profilesMu.RUnlock()
+
+	profile, ok := profiles[strings.ToUpper(model)]
+	if !ok {
+		return nil, false
+	}
+
+	profile.CodePages = slices.Clone(profile.CodePages)
+
+	return &profile, true
+}
+
+// Validate commands against the model's capabilities
+func WithProfile(profile *Profile) Option {
+	return func(p *Driver) {
+		p.profile = profile
+	}
+}
+
+// Returns the profile set through WithProfile, nil when there is none
+func (p *Driver) Profile() *Profile {
+	return p.profile
+}
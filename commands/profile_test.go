@@ -0,0 +1,139 @@
+package commands_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/cyb3rjerry/rongta-escpos/commands"
+)
+
+func lookup(t *testing.T, model string) *commands.Profile {
+	t.Helper()
+
+	profile, ok := commands.LookupProfile(model)
+	if !ok {
+		t.Fatalf("no profile for %s", model)
+	}
+
+	return profile
+}
+
+func TestBuiltinProfiles(t *testing.T) {
+	for _, tt := range []struct {
+		model      string
+		paperWidth commands.Millimetres
+		dots       commands.Dots
+		cutter     bool
+		pageMode   bool
+		beeper     bool
+		nvMemory   int
+		allTables  bool
+	}{
+		{"RP325", 58, 384, false, false, false, 64 * 1024, false},
+		{"RP326", 80, 576, true, true, false, 192 * 1024, true},
+		{"rp327", 80, 576, true, true, true, 192 * 1024, true},
+		{"RP328", 80, 576, true, true, true, 256 * 1024, true},
+	} {
+		profile := lookup(t, tt.model)
+
+		if profile.PaperWidth != tt.paperWidth || profile.PrintableDots != tt.dots {
+			t.Errorf("%s: %v mm, %d dots, want %v mm, %d dots", tt.model, profile.PaperWidth, profile.PrintableDots, tt.paperWidth, tt.dots)
+		}
+
+		if profile.Cutter != tt.cutter || profile.PageMode != tt.pageMode || profile.Beeper != tt.beeper {
+			t.Errorf("%s: got cutter %t, page mode %t, beeper %t", tt.model, profile.Cutter, profile.PageMode, profile.Beeper)
+		}
+
+		if profile.NVMemory != tt.nvMemory {
+			t.Errorf("%s: %d bytes of NV memory, want %d", tt.model, profile.NVMemory, tt.nvMemory)
+		}
+
+		if !profile.SupportsCodePage(commands.CP437) || profile.SupportsCodePage(commands.Thai) != tt.allTables {
+			t.Errorf("%s: wrong code tables %v", tt.model, profile.CodePages)
+		}
+	}
+}
+
+// The commands a model lacks fail without being sent
+func TestBuiltinProfileRejectsCommands(t *testing.T) {
+	narrow, sim := newDriver(t, commands.WithProfile(lookup(t, "RP325")))
+
+	for name, err := range map[string]error{
+		"Cut":                              narrow.Cut(),
+		"SelectCutModeAndCutPaper":         narrow.SelectCutModeAndCutPaper(0),
+		"SelectPageMode":                   narrow.SelectPageMode(),
+		"SetBeepPrompt":                    narrow.SetBeepPrompt(1, 1),
+		"SelectInternationalCharacterCode": narrow.SelectInternationalCharacterCode(commands.Thai),
+	} {
+		if !errors.Is(err, commands.ErrUnsupportedCommand) {
+			t.Errorf("%s: got %v, want ErrUnsupportedCommand", name, err)
+		}
+	}
+
+	// Wider than the 384 dots of a 58 mm roll
+	if err := narrow.PrintRasterImage(0, 576, 1, make([]byte, 72)); !errors.Is(err, commands.ErrRasterImageSize) {
+		t.Errorf("PrintRasterImage: got %v, want ErrRasterImageSize", err)
+	}
+
+	if got := sim.Raw(); len(got) > 0 {
+		t.Errorf("sent % x", got)
+	}
+
+	// The model with a buzzer beeps
+	beeper, sim := newDriver(t, commands.WithProfile(lookup(t, "RP327")))
+	if err := beeper.SetBeepPrompt(2, 3); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := sim.Raw(), []byte{commands.ESC, 'B', 2, 3}; !bytes.Equal(got, want) {
+		t.Errorf("sent % x, want % x", got, want)
+	}
+}
+
+func TestProfileRejectsUnsupportedCommands(t *testing.T) {
+	profile := &commands.Profile{
+		Model:     "Narrow",
+		CodePages: []commands.CharacterCode{commands.CP437},
+	}
+	profile.Default()
+	profile.PrintableDots = 512
+
+	d, sim := newDriver(t, commands.WithProfile(profile))
+
+	if err := d.SetBeepPrompt(1, 1); !errors.Is(err, commands.ErrUnsupportedCommand) {
+		t.Errorf("SetBeepPrompt: got %v, want ErrUnsupportedCommand", err)
+	}
+
+	if err := d.SelectInternationalCharacterCode(commands.Thai); !errors.Is(err, commands.ErrUnsupportedCommand) {
+		t.Errorf("SelectInternationalCharacterCode: got %v, want ErrUnsupportedCommand", err)
+	}
+
+	if err := d.PrintRasterImage(0, 576, 1, make([]byte, 72)); !errors.Is(err, commands.ErrRasterImageSize) {
+		t.Errorf("PrintRasterImage: got %v, want ErrRasterImageSize", err)
+	}
+
+	if err := d.SelectInternationalCharacterCode(commands.CP437); err != nil {
+		t.Error(err)
+	}
+
+	if got, want := sim.Raw(), []byte{commands.ESC, 't', byte(commands.CP437)}; !bytes.Equal(got, want) {
+		t.Errorf("sent % x, want % x", got, want)
+	}
+}
+
+func TestRegisterProfile(t *testing.T) {
+	err := commands.RegisterProfile(commands.Profile{Model: "Custom58", PaperWidth: 58})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	profile := lookup(t, "CUSTOM58")
+	if profile.PrintableDots != 384 {
+		t.Errorf("got %d dots, want 384 from the paper width", profile.PrintableDots)
+	}
+
+	if err := commands.RegisterProfile(commands.Profile{}); !errors.Is(err, commands.ErrInvalidProfile) {
+		t.Errorf("got %v, want ErrInvalidProfile", err)
+	}
+}
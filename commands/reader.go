@@ -0,0 +1,398 @@
+package commands
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrClosed = errors.New("driver closed")
+)
+
+// Automatic Status Back frame, pushed by the printer once ASB is enabled
+// with ToggleASB
+type ASBFrame [4]byte
+
+const (
+	// First ASB byte is 0xx1xx00, the next three are 0xx0xxxx
+	ASB_HEADER_FIXED_MASK uint8 = 0x93
+	ASB_HEADER_FIXED_BITS uint8 = 0x10
+	ASB_BODY_FIXED_MASK   uint8 = 0x90
+	ASB_BODY_FIXED_BITS   uint8 = 0x00
+
+	// Variable length replies are framed by a header and a NUL
+	BLOCK_REPLY_HEADER uint8 = 0x5F
+)
+
+// Tells whether b holds a whole reply
+type frame func(b []byte) bool
+
+// Reply of exactly n bytes
+func fixedFrame(n int) frame {
+	return func(b []byte) bool {
+		return len(b) >= n
+	}
+}
+
+// Either a single byte, or a block starting with BLOCK_REPLY_HEADER and
+// ending with NUL
+func blockFrame(b []byte) bool {
+	if b[0] != BLOCK_REPLY_HEADER {
+		return true
+	}
+
+	return len(b) > 1 && b[len(b)-1] == NUL
+}
+
+// Time a request that gave up keeps its place in the queue, so that its
+// late reply isn't handed to the next request
+var lateReplyTimeout = 2 * time.Second
+
+// Time the rest of a partial ASB frame is waited for. A lone byte that
+// looks like an ASB header, e.g. a printer ID of 0x30, is the reply of the
+// waiting request.
+var asbFragmentTimeout = 100 * time.Millisecond
+
+// Request waiting for its reply
+type waiter struct {
+	complete frame
+	reply    []byte
+	done     chan struct{}
+	err      error
+
+	// When the request was queued
+	since time.Time
+
+	// Nobody waits for the reply anymore, it is dropped when it arrives
+	// unless it takes longer than lateReplyTimeout
+	discarded bool
+	expires   time.Time
+}
+
+// The background reader owns the input stream. It is started by the first
+// reply or ASB subscription, and splits the incoming bytes between ASB
+// subscribers and the requests waiting for a reply, oldest first. Bytes
+// nobody is waiting for are dropped.
+func (p *Driver) startReader() {
+	p.rmu.Lock()
+	defer p.rmu.Unlock()
+
+	if p.reading || p.closed {
+		return
+	}
+
+	p.reading = true
+	go p.readLoop()
+}
+
+func (p *Driver) readLoop() {
+	buf := make([]byte, 256)
+
+	for {
+		start := time.Now()
+		n, err := p.rwc.Read(buf)
+		p.dispatch(buf[:n])
+
+		if err == nil {
+			continue
+		}
+
+		if isTimeout(err) {
+			p.timeoutWaiters(start, err)
+			continue
+		}
+
+		p.rmu.Lock()
+		p.failWaiters(err)
+
+		// The connection may come back (e.g. by reconnecting), it is read
+		// again until the driver is closed
+		if p.closed {
+			p.reading = false
+			p.rmu.Unlock()
+			return
+		}
+		p.rmu.Unlock()
+
+		select {
+		case <-time.After(time.Second):
+		case <-p.done:
+		}
+	}
+}
+
+// Routes incoming bytes to ASB subscribers and waiting requests
+func (p *Driver) dispatch(b []byte) {
+	p.rmu.Lock()
+	defer p.rmu.Unlock()
+
+	if len(b) == 0 {
+		return
+	}
+
+	// Bytes came in, the partial ASB frame is still being received
+	p.asbGen++
+
+	for _, c := range b {
+		p.dispatchByte(c)
+	}
+
+	// The rest of a partial ASB frame may be in the next read. It is only
+	// handed to the waiting request when the line stays quiet.
+	if len(p.asb) > 0 && len(p.waiters) > 0 {
+		gen := p.asbGen
+		time.AfterFunc(asbFragmentTimeout, func() {
+			p.rmu.Lock()
+			defer p.rmu.Unlock()
+
+			if gen == p.asbGen {
+				p.flushASB()
+			}
+		})
+	}
+}
+
+// Hands the partial ASB frame to the waiting request, it wasn't an ASB
+// frame after all. Must be called with rmu held.
+func (p *Driver) flushASB() {
+	pending := p.asb
+	p.asb = nil
+
+	for _, c := range pending {
+		p.deliver(c)
+	}
+}
+
+// Must be called with rmu held
+func (p *Driver) dispatchByte(c byte) {
+	if len(p.asb) > 0 {
+		if c&ASB_BODY_FIXED_MASK == ASB_BODY_FIXED_BITS {
+			p.asb = append(p.asb, c)
+
+			if len(p.asb) == len(ASBFrame{}) {
+				p.publish(ASBFrame(p.asb))
+				p.asb = nil
+			}
+
+			return
+		}
+
+		// Not an ASB frame after all
+		p.flushASB()
+	}
+
+	// A reply in progress isn't interrupted, its bytes can look like an
+	// ASB header
+	inReply := len(p.waiters) > 0 && len(p.waiters[0].reply) > 0
+
+	if !inReply && c&ASB_HEADER_FIXED_MASK == ASB_HEADER_FIXED_BITS {
+		p.asb = append(p.asb, c)
+		return
+	}
+
+	p.deliver(c)
+}
+
+// Hands c to the oldest waiting request, must be called with rmu held
+func (p *Driver) deliver(c byte) {
+	p.dropExpired()
+
+	if len(p.waiters) == 0 {
+		return
+	}
+
+	w := p.waiters[0]
+	w.reply = append(w.reply, c)
+
+	if w.complete(w.reply) {
+		p.waiters = p.waiters[1:]
+
+		if !w.discarded {
+			close(w.done)
+		}
+	}
+}
+
+// Must be called with rmu held
+func (p *Driver) publish(f ASBFrame) {
+	for _, ch := range p.subscribers {
+		// Slow subscribers miss frames rather than block the reader
+		select {
+		case ch <- f:
+		default:
+		}
+	}
+}
+
+// Gives up on the late replies that never came, must be called with rmu
+// held
+func (p *Driver) dropExpired() {
+	now := time.Now()
+
+	for len(p.waiters) > 0 && p.waiters[0].discarded && now.After(p.waiters[0].expires) {
+		p.waiters = p.waiters[1:]
+	}
+}
+
+// Must be called with rmu held
+func (p *Driver) failWaiters(err error) {
+	for _, w := range p.waiters {
+		if w.discarded {
+			continue
+		}
+
+		w.err = err
+		close(w.done)
+	}
+
+	p.waiters = nil
+}
+
+// Fails the requests that waited a whole read timeout of the connection
+// (e.g. TCPConfig.ReadTimeout), which ends requests made without a context
+// deadline. Like requests whose context ended, they keep their place in the
+// queue for lateReplyTimeout.
+func (p *Driver) timeoutWaiters(start time.Time, err error) {
+	p.rmu.Lock()
+	defer p.rmu.Unlock()
+
+	for _, w := range p.waiters {
+		if w.discarded || !w.since.Before(start) {
+			continue
+		}
+
+		w.discarded = true
+		w.expires = time.Now().Add(lateReplyTimeout)
+		w.err = &TimeoutError{Op: "read", Err: err}
+		close(w.done)
+	}
+}
+
+// Queues a request for the reply framed by complete
+// Must be called before the request is written.
+func (p *Driver) expect(complete frame) (*waiter, error) {
+	w := &waiter{complete: complete, done: make(chan struct{}), since: time.Now()}
+
+	p.rmu.Lock()
+	if p.closed {
+		p.rmu.Unlock()
+		return nil, ErrClosed
+	}
+	p.dropExpired()
+	p.waiters = append(p.waiters, w)
+	p.rmu.Unlock()
+
+	p.startReader()
+
+	return w, nil
+}
+
+// Removes a request whose write failed, nothing will answer it
+func (p *Driver) forget(w *waiter) {
+	p.rmu.Lock()
+	defer p.rmu.Unlock()
+
+	for i, other := range p.waiters {
+		if other == w {
+			p.waiters = append(p.waiters[:i:i], p.waiters[i+1:]...)
+			return
+		}
+	}
+}
+
+// Waits for the reply of w
+// A request whose context ends keeps its place in the queue for
+// lateReplyTimeout, so its late reply is dropped instead of being handed
+// to the next request.
+func (p *Driver) wait(w *waiter) ([]byte, error) {
+	select {
+	case <-w.done:
+		return w.reply, w.err
+	case <-p.ctx.Done():
+		p.rmu.Lock()
+		defer p.rmu.Unlock()
+
+		// The reply may have completed meanwhile
+		select {
+		case <-w.done:
+			return w.reply, w.err
+		default:
+		}
+
+		w.discarded = true
+		w.expires = time.Now().Add(lateReplyTimeout)
+
+		return nil, contextError("read", p.ctx.Err())
+	}
+}
+
+// Subscribe to ASB frames
+// The channel is closed by the returned cancel function or when the driver
+// is closed. Frames are dropped while the channel is full.
+func (p *Driver) SubscribeASB() (<-chan ASBFrame, func()) {
+	ch := make(chan ASBFrame, 16)
+
+	p.rmu.Lock()
+	if p.closed {
+		p.rmu.Unlock()
+		close(ch)
+		return ch, func() {}
+	}
+
+	id := p.nextSubscriber
+	p.nextSubscriber++
+	p.subscribers[id] = ch
+	p.rmu.Unlock()
+
+	p.startReader()
+
+	return ch, func() {
+		p.rmu.Lock()
+		defer p.rmu.Unlock()
+
+		if _, ok := p.subscribers[id]; ok {
+			delete(p.subscribers, id)
+			close(ch)
+		}
+	}
+}
+
+// Closes the connection, stops the reader and ends the subscriptions
+// Pending and later requests fail with ErrClosed.
+func (p *Driver) Close() error {
+	p.rmu.Lock()
+	if p.closed {
+		p.rmu.Unlock()
+		return ErrClosed
+	}
+
+	p.closed = true
+	close(p.done)
+	p.failWaiters(ErrClosed)
+
+	for id, ch := range p.subscribers {
+		delete(p.subscribers, id)
+		close(ch)
+	}
+	p.rmu.Unlock()
+
+	if p.rwc == nil {
+		return nil
+	}
+
+	return p.rwc.Close()
+}
+
+func (p *Driver) isClosed() bool {
+	p.rmu.Lock()
+	defer p.rmu.Unlock()
+
+	return p.closed
+}
+
+// Read timeouts of the underlying connection fail the waiting requests but
+// don't end the reader
+func isTimeout(err error) bool {
+	var timeout interface{ Timeout() bool }
+	return errors.As(err, &timeout) && timeout.Timeout()
+}
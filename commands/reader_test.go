@@ -0,0 +1,271 @@
+package commands_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cyb3rjerry/rongta-escpos/commands"
+	"github.com/cyb3rjerry/rongta-escpos/rongtasim"
+)
+
+const (
+	statusOK     = commands.REALTIME_STATUS_FIXED_BITS
+	statusJammed = commands.REALTIME_STATUS_FIXED_BITS | commands.AUTOCUTER_STATUS_MASK
+)
+
+func withTimeout(t *testing.T, d *commands.Driver, timeout time.Duration) *commands.Driver {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	t.Cleanup(cancel)
+
+	return d.WithContext(ctx)
+}
+
+// Virtual printer that stops answering while muted
+type muteConn struct {
+	*rongtasim.Printer
+
+	muted atomic.Bool
+
+	// Like TCPConfig.ReadTimeout, every read times out after readTimeout
+	readTimeout time.Duration
+}
+
+func (c *muteConn) Write(b []byte) (int, error) {
+	if c.muted.Load() {
+		return len(b), nil
+	}
+
+	return c.Printer.Write(b)
+}
+
+func (c *muteConn) Read(b []byte) (int, error) {
+	if c.readTimeout > 0 {
+		c.Printer.SetReadDeadline(time.Now().Add(c.readTimeout))
+	}
+
+	return c.Printer.Read(b)
+}
+
+// Initialize a driver on a muted virtual printer
+func newMutedDriver(t *testing.T, readTimeout time.Duration, opts ...commands.Option) (*commands.Driver, *muteConn) {
+	t.Helper()
+
+	conn := &muteConn{Printer: rongtasim.New(), readTimeout: readTimeout}
+	conn.muted.Store(true)
+	t.Cleanup(func() { conn.Close() })
+
+	return commands.NewDriver(conn, opts...), conn
+}
+
+func TestQueryReplyArrivingImmediately(t *testing.T) {
+	d, _ := newDriver(t)
+
+	// The reply is sent from within Write, before the query could start
+	// waiting for it if it registered late
+	for i := 0; i < 2000; i++ {
+		jammed, err := withTimeout(t, d, time.Second).GetAutocutterStatus()
+		if err != nil {
+			t.Fatalf("iteration %d: %v", i, err)
+		}
+
+		if jammed {
+			t.Fatalf("iteration %d: cutter reported jammed", i)
+		}
+	}
+}
+
+func TestLateReplyIsDiscarded(t *testing.T) {
+	d, conn := newMutedDriver(t, 0)
+
+	_, err := withTimeout(t, d, 50*time.Millisecond).GetAutocutterStatus()
+	if !errors.Is(err, commands.ErrTimeout) {
+		t.Fatalf("got %v, want ErrTimeout", err)
+	}
+
+	// The reply to the abandoned request arrives, then the next one
+	conn.Inject([]byte{statusJammed})
+	conn.muted.Store(false)
+
+	jammed, err := withTimeout(t, d, time.Second).GetAutocutterStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if jammed {
+		t.Error("late reply handed to the next request")
+	}
+}
+
+func TestMissingLateReplyExpires(t *testing.T) {
+	defer func(d time.Duration) { *commands.LateReplyTimeout = d }(*commands.LateReplyTimeout)
+	*commands.LateReplyTimeout = 50 * time.Millisecond
+
+	d, conn := newMutedDriver(t, 0)
+
+	_, err := withTimeout(t, d, 50*time.Millisecond).GetAutocutterStatus()
+	if !errors.Is(err, commands.ErrTimeout) {
+		t.Fatalf("got %v, want ErrTimeout", err)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	conn.SetStatus(3, statusJammed)
+	conn.muted.Store(false)
+
+	jammed, err := withTimeout(t, d, time.Second).GetAutocutterStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !jammed {
+		t.Error("cutter reported working")
+	}
+}
+
+// Queries made without a context end with the read timeout of the
+// connection
+func TestReadTimeoutEndsQuery(t *testing.T) {
+	d, conn := newMutedDriver(t, 50*time.Millisecond)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := d.GetAutocutterStatus()
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, commands.ErrTimeout) {
+			t.Fatalf("got %v, want ErrTimeout", err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("query still waiting after the read timeout")
+	}
+
+	// The late reply is dropped, the next query gets its own
+	conn.Inject([]byte{statusJammed})
+	conn.muted.Store(false)
+
+	jammed, err := d.GetAutocutterStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if jammed {
+		t.Error("late reply handed to the next request")
+	}
+}
+
+// Fails one read, like a connection that drops and comes back
+type flakyConn struct {
+	*rongtasim.Printer
+
+	mu   sync.Mutex
+	fail bool
+}
+
+func (c *flakyConn) Read(b []byte) (int, error) {
+	c.mu.Lock()
+	fail := c.fail
+	c.fail = false
+	c.mu.Unlock()
+
+	if fail {
+		return 0, io.EOF
+	}
+
+	return c.Printer.Read(b)
+}
+
+func TestReaderSurvivesReadErrors(t *testing.T) {
+	conn := &flakyConn{Printer: rongtasim.New(), fail: true}
+	d := commands.NewDriver(conn)
+	defer d.Close()
+
+	frames, cancel := d.SubscribeASB()
+	defer cancel()
+
+	frame := commands.ASBFrame{0x10 | 0x20, 0x00, 0x00, 0x00}
+	conn.Inject(frame[:])
+
+	select {
+	case got := <-frames:
+		if got != frame {
+			t.Errorf("got frame % x, want % x", got, frame)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("frame not delivered after a read error")
+	}
+}
+
+func TestSubscriptionsEndOnClose(t *testing.T) {
+	d, _ := newDriver(t)
+
+	frames, _ := d.SubscribeASB()
+	d.Close()
+
+	select {
+	case _, ok := <-frames:
+		if ok {
+			t.Error("frame received after Close")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("subscription still open after Close")
+	}
+}
+
+// The printer pushes an ASB frame in pieces while a query waits, the
+// frame must not be mistaken for the reply
+func TestSplitASBFrameDuringQuery(t *testing.T) {
+	d, sim := newDriver(t)
+
+	frames, cancel := d.SubscribeASB()
+	defer cancel()
+
+	frame := commands.ASBFrame{commands.ASB_HEADER_FIXED_BITS | 0x20, 0x00, 0x0C, 0x00}
+	sim.SetStatus(3, frame[0])
+
+	go func() {
+		for _, b := range [][]byte{frame[1:2], frame[2:], {statusJammed}} {
+			time.Sleep(20 * time.Millisecond)
+			sim.Inject(b)
+		}
+	}()
+
+	jammed, err := withTimeout(t, d, time.Second).GetAutocutterStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !jammed {
+		t.Error("cutter reported working")
+	}
+
+	select {
+	case got := <-frames:
+		if got != frame {
+			t.Errorf("got frame % x, want % x", got, frame)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no frame received")
+	}
+}
+
+// A single byte reply with the ASB header bits set is still the reply
+func TestReplyLookingLikeASBHeader(t *testing.T) {
+	d, sim := newDriver(t)
+	sim.SetPrinterID(uint8(commands.PrinterModelID), []byte{commands.ASB_HEADER_FIXED_BITS | 0x20})
+
+	id, err := withTimeout(t, d, time.Second).TransmitPrinterID(commands.PrinterModelID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(id) != 1 || id[0] != commands.ASB_HEADER_FIXED_BITS|0x20 {
+		t.Errorf("got % x, want 30", id)
+	}
+}
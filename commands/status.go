@@ -1,5 +1,11 @@
 package commands
 
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
 // Real-time status transmission commands
 // https://www.manualslib.com/manual/3423402/Rongta-Technology-Rp325.html
 
@@ -7,11 +13,15 @@ type PrinterStatus string
 
 const (
 	// Printer status information bitmasks
-	DRAWER_OPEN_CLOSE_STATUS_MASK uint8 = 0x04
+	DRAWER_OPEN_CLOSE_STATUS_MASK   uint8 = 0x04 // 0 = Pin 3 LOW, 4 = Pin 3 HIGH
+	OFFLINE_STATUS_MASK             uint8 = 0x08 // 0 = Online, 8 = Offline or busy
+	FEED_BUTTON_PRESSED_STATUS_MASK uint8 = 0x40 // 0 = Released, 0x40 = Pressed
 
 	// Offline status information bitmasks
-	COVER_STATUS_MASK       uint8 = 0x04 // 0 = Closed, 4 = Open
-	FEED_BUTTON_STATUS_MASK uint8 = 0x08 // 0 = Pressed, 8 = Released
+	COVER_STATUS_MASK          uint8 = 0x04 // 0 = Closed, 4 = Open
+	FEED_BUTTON_STATUS_MASK    uint8 = 0x08 // 0 = Not feeding, 8 = Paper fed by the feed button
+	PAPER_END_STOP_STATUS_MASK uint8 = 0x20 // 0 = Printing, 0x20 = Stopped at the paper end
+	ERROR_STATUS_MASK          uint8 = 0x40 // 0 = No error, 0x40 = Error
 
 	// Error status information bitmasks
 	AUTOCUTER_STATUS_MASK             uint8 = 0x08
@@ -19,18 +29,223 @@ const (
 	AUTORECOVERABLE_ERROR_STATUS_MASK uint8 = 0x40
 
 	// Continuous paper detector status information bitmasks
-	PAPER_PRESENT_STATUS_MASK uint8 = 0x60
+	PAPER_NEAR_END_STATUS_MASK uint8 = 0x0C
+	PAPER_PRESENT_STATUS_MASK  uint8 = 0x60 // 0 = Paper present, 0x60 = Paper end
+
+	// GS r 1 paper sensor bitmasks, bits 4 and 7 are cleared
+	PAPER_SENSOR_NEAR_END_MASK uint8 = 0x03
+	PAPER_SENSOR_END_MASK      uint8 = 0x0C
+	PAPER_SENSOR_FIXED_MASK    uint8 = 0x90
+
+	// Every real-time status byte has bits 1 and 4 set, bits 0 and 7 cleared
+	REALTIME_STATUS_FIXED_MASK uint8 = 0x93
+	REALTIME_STATUS_FIXED_BITS uint8 = 0x12
 )
 
-// Get the status of the printer cover
-// Returns true if the cover is pin 3 is HIGH, false if it's LOW
+var (
+	ErrInvalidStatusReply = errors.New("invalid status reply")
+	ErrNotReady           = errors.New("printer not ready")
+)
+
+// Printer state reported by DLE EOT 1 to 4
+type Status struct {
+	// DLE EOT 1, printer status
+	DrawerPinHigh     bool // Drawer kick-out connector pin 3
+	Offline           bool
+	FeedButtonPressed bool
+
+	// DLE EOT 2, offline cause
+	CoverOpen       bool
+	FeedingByButton bool // Paper is being fed by the feed button
+	PaperEndStop    bool // Printing stopped at the paper end
+	Error           bool
+
+	// DLE EOT 3, error cause
+	CutterError          bool
+	UnrecoverableError   bool
+	AutoRecoverableError bool
+
+	// DLE EOT 4, paper roll sensor
+	PaperNearEnd bool
+	PaperEnd     bool
+}
+
+// Lists the conditions that are set, "ok" when there is none
+func (s Status) String() string {
+	var conditions []string
+
+	for _, c := range []struct {
+		set  bool
+		name string
+	}{
+		{s.DrawerPinHigh, "drawer pin high"},
+		{s.Offline, "offline"},
+		{s.FeedButtonPressed, "feed button pressed"},
+		{s.CoverOpen, "cover open"},
+		{s.FeedingByButton, "feeding by button"},
+		{s.PaperEndStop, "stopped at paper end"},
+		{s.Error, "error"},
+		{s.CutterError, "cutter error"},
+		{s.UnrecoverableError, "unrecoverable error"},
+		{s.AutoRecoverableError, "auto-recoverable error"},
+		{s.PaperNearEnd, "paper near end"},
+		{s.PaperEnd, "paper end"},
+	} {
+		if c.set {
+			conditions = append(conditions, c.name)
+		}
+	}
+
+	if len(conditions) == 0 {
+		return "ok"
+	}
+
+	return strings.Join(conditions, ", ")
+}
+
+// Paper roll sensor state
+func (s Status) Paper() PaperStatus {
+	switch {
+	case s.PaperEnd:
+		return PaperStatusOut
+	case s.PaperNearEnd:
+		return PaperStatusLow
+	}
+
+	return PaperStatusOK
+}
+
+func (s PaperStatus) String() string {
+	switch s {
+	case PaperStatusOK:
+		return "ok"
+	case PaperStatusLow:
+		return "near end"
+	case PaperStatusOut:
+		return "out"
+	}
+
+	return fmt.Sprintf("PaperStatus(%#02x)", uint8(s))
+}
+
+// Decodes the paper roll sensor bits of a DLE EOT 4 reply
+// Paper end takes precedence over near end.
+func paperStatus(b uint8) PaperStatus {
+	switch {
+	case b&PAPER_PRESENT_STATUS_MASK != 0:
+		return PaperStatusOut
+	case b&PAPER_NEAR_END_STATUS_MASK != 0:
+		return PaperStatusLow
+	}
+
+	return PaperStatusOK
+}
+
+// Decodes a GS r 1 reply, its bits differ from DLE EOT 4
+// Paper end takes precedence over near end.
+func paperSensorStatus(b uint8) PaperStatus {
+	switch {
+	case b&PAPER_SENSOR_END_MASK != 0:
+		return PaperStatusOut
+	case b&PAPER_SENSOR_NEAR_END_MASK != 0:
+		return PaperStatusLow
+	}
+
+	return PaperStatusOK
+}
+
+// Reports whether b looks like a reply to a DLE EOT status request
+func IsRealTimeStatus(b uint8) bool {
+	return b&REALTIME_STATUS_FIXED_MASK == REALTIME_STATUS_FIXED_BITS
+}
+
+// Queries DLE EOT 1 to 4 in a row
+// A reply whose fixed bits are wrong fails with ErrInvalidStatusReply and
+// ErrProtocol instead of being misread.
+func (p *Driver) Status() (Status, error) {
+	var status Status
+
+	err := p.Job(func(d *Driver) error {
+		var err error
+
+		status, err = d.status()
+		return err
+	})
+
+	return status, err
+}
+
+// Reads the status and fails with ErrNotReady and ErrPrinterState when the
+// printer can't print: offline, cover open, in error or out of paper
+// Paper near end is not an error.
+func (p *Driver) CheckReady() error {
+	status, err := p.Status()
+	if err != nil {
+		return err
+	}
+
+	if !status.ready() {
+		return stateError("CheckReady", fmt.Errorf("%w: %s", ErrNotReady, status))
+	}
+
+	return nil
+}
+
+// Status, the caller has exclusive access
+func (p *Driver) status() (Status, error) {
+	var replies [4]uint8
+
+	for i := range replies {
+		var err error
+
+		replies[i], err = p.getTransmitStatus(uint8(i + 1))
+		if err != nil {
+			return Status{}, err
+		}
+	}
+
+	printer, offline, errs, paper := replies[0], replies[1], replies[2], replies[3]
+
+	return Status{
+		DrawerPinHigh:     printer&DRAWER_OPEN_CLOSE_STATUS_MASK != 0,
+		Offline:           printer&OFFLINE_STATUS_MASK != 0,
+		FeedButtonPressed: printer&FEED_BUTTON_PRESSED_STATUS_MASK != 0,
+
+		CoverOpen:       offline&COVER_STATUS_MASK != 0,
+		FeedingByButton: offline&FEED_BUTTON_STATUS_MASK != 0,
+		PaperEndStop:    offline&PAPER_END_STOP_STATUS_MASK != 0,
+		Error:           offline&ERROR_STATUS_MASK != 0,
+
+		CutterError:          errs&AUTOCUTER_STATUS_MASK != 0,
+		UnrecoverableError:   errs&UNRECOVERABLE_ERROR_STATUS_MASK != 0,
+		AutoRecoverableError: errs&AUTORECOVERABLE_ERROR_STATUS_MASK != 0,
+
+		PaperNearEnd: paper&PAPER_NEAR_END_STATUS_MASK != 0,
+		PaperEnd:     paper&PAPER_PRESENT_STATUS_MASK != 0,
+	}, nil
+}
+
+// Get the status of the paper roll sensor (DLE EOT 4)
+// Answered in real time, even while the printer is busy with print data
+func (p *Driver) GetPaperStatus() (PaperStatus, error) {
+	status, err := p.getPaperSensorStatus()
+	if err != nil {
+		return PaperStatusLow, err
+	}
+
+	return paperStatus(status), nil
+}
+
+// Get the status of the cash drawer
+// Returns true if the drawer kick-out connector pin 3 is HIGH, false if
+// it's LOW
 func (p *Driver) GetDrawerStatus() (bool, error) {
 	status, err := p.getPrinterStatus()
 	if err != nil {
 		return false, err
 	}
 
-	return status&DRAWER_OPEN_CLOSE_STATUS_MASK == 0, nil
+	return status&DRAWER_OPEN_CLOSE_STATUS_MASK != 0, nil
 }
 
 // Get the status of the printer cover
@@ -41,18 +256,18 @@ func (p *Driver) GetCoverStatus() (bool, error) {
 		return false, err
 	}
 
-	return status&COVER_STATUS_MASK == 0, nil
+	return status&COVER_STATUS_MASK != 0, nil
 }
 
 // Get the status of the feed button
 // Returns true if the feed button is pressed, false if it's released
 func (p *Driver) GetFeedButtonStatus() (bool, error) {
-	status, err := p.getOfflineStatus()
+	status, err := p.getPrinterStatus()
 	if err != nil {
 		return false, err
 	}
 
-	return status&FEED_BUTTON_STATUS_MASK == 0, nil
+	return status&FEED_BUTTON_PRESSED_STATUS_MASK != 0, nil
 }
 
 // Get the status of the autocutter
@@ -103,14 +318,21 @@ func (p *Driver) getErrorStatus() (uint8, error) {
 	return p.getTransmitStatus(0x03)
 }
 
-func (p *Driver) getTransmitStatus(statusType uint8) (uint8, error) {
-	status := make([]byte, 1)
+// Continuous paper sensor status
+func (p *Driver) getPaperSensorStatus() (uint8, error) {
+	return p.getTransmitStatus(0x04)
+}
 
-	_, err := p.rwc.Write([]byte{DLE, EOT, statusType})
+func (p *Driver) getTransmitStatus(statusType uint8) (uint8, error) {
+	status, err := p.query("TransmitRealTimeStatus", []byte{DLE, EOT, statusType}, fixedFrame(1))
 	if err != nil {
 		return 0, err
 	}
 
-	_, err = p.rwc.Read(status)
-	return status[0], err
+	if !IsRealTimeStatus(status[0]) {
+		err := fmt.Errorf("%w: DLE EOT %d replied %#02x", ErrInvalidStatusReply, statusType, status[0])
+		return 0, protocolError("TransmitRealTimeStatus", err)
+	}
+
+	return status[0], nil
 }
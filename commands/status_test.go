@@ -0,0 +1,216 @@
+package commands_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cyb3rjerry/rongta-escpos/commands"
+	"github.com/cyb3rjerry/rongta-escpos/commands/commandstest"
+	"github.com/cyb3rjerry/rongta-escpos/rongtasim"
+)
+
+// Every DLE EOT 1 to 4 bit, the Status field it sets and the getter
+// reading it, if any
+var statusBits = []struct {
+	name   string
+	n      uint8
+	mask   uint8
+	set    func(*commands.Status)
+	getter func(*commands.Driver) (bool, error)
+}{
+	{"drawer", 1, commands.DRAWER_OPEN_CLOSE_STATUS_MASK, func(s *commands.Status) { s.DrawerPinHigh = true }, (*commands.Driver).GetDrawerStatus},
+	{"offline", 1, commands.OFFLINE_STATUS_MASK, func(s *commands.Status) { s.Offline = true }, nil},
+	{"feed button", 1, commands.FEED_BUTTON_PRESSED_STATUS_MASK, func(s *commands.Status) { s.FeedButtonPressed = true }, (*commands.Driver).GetFeedButtonStatus},
+	{"cover", 2, commands.COVER_STATUS_MASK, func(s *commands.Status) { s.CoverOpen = true }, (*commands.Driver).GetCoverStatus},
+	{"feeding", 2, commands.FEED_BUTTON_STATUS_MASK, func(s *commands.Status) { s.FeedingByButton = true }, nil},
+	{"paper end stop", 2, commands.PAPER_END_STOP_STATUS_MASK, func(s *commands.Status) { s.PaperEndStop = true }, nil},
+	{"error", 2, commands.ERROR_STATUS_MASK, func(s *commands.Status) { s.Error = true }, nil},
+	{"cutter", 3, commands.AUTOCUTER_STATUS_MASK, func(s *commands.Status) { s.CutterError = true }, (*commands.Driver).GetAutocutterStatus},
+	{"unrecoverable", 3, commands.UNRECOVERABLE_ERROR_STATUS_MASK, func(s *commands.Status) { s.UnrecoverableError = true }, (*commands.Driver).GetUnrecoverableErrorStatus},
+	{"autorecoverable", 3, commands.AUTORECOVERABLE_ERROR_STATUS_MASK, func(s *commands.Status) { s.AutoRecoverableError = true }, (*commands.Driver).GetAutorecoverableErrorStatus},
+	{"paper near end", 4, commands.PAPER_NEAR_END_STATUS_MASK, func(s *commands.Status) { s.PaperNearEnd = true }, nil},
+	{"paper end", 4, commands.PAPER_PRESENT_STATUS_MASK, func(s *commands.Status) { s.PaperEnd = true }, nil},
+}
+
+func TestStatusBits(t *testing.T) {
+	for _, tt := range statusBits {
+		t.Run(tt.name, func(t *testing.T) {
+			d, sim := newDriver(t)
+			sim.SetStatus(tt.n, statusOK|tt.mask)
+
+			var want commands.Status
+			tt.set(&want)
+
+			got, err := withTimeout(t, d, time.Second).Status()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got != want {
+				t.Errorf("DLE EOT %d reply %#02x: got %s, want %s", tt.n, statusOK|tt.mask, got, want)
+			}
+
+			// Only the getter for this bit reports it
+			for _, other := range statusBits {
+				if other.getter == nil {
+					continue
+				}
+
+				set, err := other.getter(withTimeout(t, d, time.Second))
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if set != (other.name == tt.name) {
+					t.Errorf("%s getter reported %t with DLE EOT %d reply %#02x", other.name, set, tt.n, statusOK|tt.mask)
+				}
+			}
+		})
+	}
+}
+
+func TestIdleStatus(t *testing.T) {
+	d, _ := newDriver(t)
+
+	got, err := withTimeout(t, d, time.Second).Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got != (commands.Status{}) || got.String() != "ok" {
+		t.Errorf("got %s, want ok", got)
+	}
+}
+
+func TestStatusString(t *testing.T) {
+	s := commands.Status{CoverOpen: true, PaperEnd: true}
+
+	if got, want := s.String(), "cover open, paper end"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestStatusRejectsNoise(t *testing.T) {
+	d, sim := newDriver(t)
+	sim.SetStatus(2, 0x90)
+
+	_, err := withTimeout(t, d, time.Second).Status()
+	if !errors.Is(err, commands.ErrInvalidStatusReply) || !errors.Is(err, commands.ErrProtocol) {
+		t.Errorf("got %v, want ErrInvalidStatusReply and ErrProtocol", err)
+	}
+}
+
+func TestTransmitStatusPaper(t *testing.T) {
+	for _, tt := range []struct {
+		reply uint8
+		want  commands.PaperStatus
+	}{
+		{0x00, commands.PaperStatusOK},
+		{0x01, commands.PaperStatusLow},
+		{0x03, commands.PaperStatusLow},
+		{0x0C, commands.PaperStatusOut},
+		{0x0F, commands.PaperStatusOut},
+	} {
+		d, conn := commandstest.NewDriver(t)
+		conn.Reply("TransmitStatus", tt.reply)
+
+		got, err := withTimeout(t, d, time.Second).TransmitStatus()
+		if err != nil {
+			t.Fatalf("GS r 1 reply %#02x: %v", tt.reply, err)
+		}
+
+		if got != tt.want {
+			t.Errorf("GS r 1 reply %#02x: got %s, want %s", tt.reply, got, tt.want)
+		}
+	}
+}
+
+func TestTransmitStatusRejectsNoise(t *testing.T) {
+	d, conn := commandstest.NewDriver(t)
+	conn.Reply("TransmitStatus", 0x90)
+
+	_, err := withTimeout(t, d, time.Second).TransmitStatus()
+	if !errors.Is(err, commands.ErrInvalidStatusReply) || !errors.Is(err, commands.ErrProtocol) {
+		t.Errorf("got %v, want ErrInvalidStatusReply and ErrProtocol", err)
+	}
+}
+
+func TestGetPaperStatus(t *testing.T) {
+	for _, tt := range []struct {
+		reply uint8
+		want  commands.PaperStatus
+	}{
+		{statusOK, commands.PaperStatusOK},
+		{statusOK | commands.PAPER_NEAR_END_STATUS_MASK, commands.PaperStatusLow},
+		{statusOK | commands.PAPER_NEAR_END_STATUS_MASK | commands.PAPER_PRESENT_STATUS_MASK, commands.PaperStatusOut},
+	} {
+		d, conn := commandstest.NewDriver(t)
+		conn.Reply("TransmitRealTimeStatus", tt.reply)
+
+		got, err := withTimeout(t, d, time.Second).GetPaperStatus()
+		if err != nil {
+			t.Fatalf("DLE EOT 4 reply %#02x: %v", tt.reply, err)
+		}
+
+		if got != tt.want {
+			t.Errorf("DLE EOT 4 reply %#02x: got %s, want %s", tt.reply, got, tt.want)
+		}
+	}
+}
+
+func TestPaperStatusAgreesWithSimulator(t *testing.T) {
+	for _, paper := range []commands.PaperStatus{
+		commands.PaperStatusOK,
+		commands.PaperStatusLow,
+		commands.PaperStatusOut,
+	} {
+		sim := rongtasim.New()
+		sim.SetPaper(paper)
+
+		d := commands.NewDriver(sim)
+		defer d.Close()
+
+		realTime, err := withTimeout(t, d, time.Second).GetPaperStatus()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		buffered, err := withTimeout(t, d, time.Second).TransmitStatus()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if realTime != paper || buffered != paper {
+			t.Errorf("paper %s: DLE EOT 4 read %s, GS r 1 read %s", paper, realTime, buffered)
+		}
+	}
+}
+
+func TestCheckReady(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		set   func(*rongtasim.Printer)
+		ready bool
+	}{
+		{"idle", func(*rongtasim.Printer) {}, true},
+		{"paper near end", func(p *rongtasim.Printer) { p.SetPaper(commands.PaperStatusLow) }, true},
+		{"paper out", func(p *rongtasim.Printer) { p.SetPaper(commands.PaperStatusOut) }, false},
+		{"cover open", func(p *rongtasim.Printer) { p.SetStatus(2, statusCoverOpen) }, false},
+		{"offline", func(p *rongtasim.Printer) { p.SetStatus(1, statusOK|commands.OFFLINE_STATUS_MASK) }, false},
+	} {
+		sim := rongtasim.New()
+		tt.set(sim)
+
+		d := commands.NewDriver(sim)
+		err := withTimeout(t, d, time.Second).CheckReady()
+		d.Close()
+
+		switch {
+		case tt.ready && err != nil:
+			t.Errorf("%s: %v", tt.name, err)
+		case !tt.ready && (!errors.Is(err, commands.ErrNotReady) || !errors.Is(err, commands.ErrPrinterState)):
+			t.Errorf("%s: got %v, want ErrNotReady and ErrPrinterState", tt.name, err)
+		}
+	}
+}
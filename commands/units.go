@@ -0,0 +1,251 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+var (
+	ErrLengthOutOfRange       = errors.New("length out of range")
+	ErrRasterImageSize        = errors.New("invalid raster image size")
+	ErrRasterImageDataMissing = errors.New("raster image data doesn't match its size")
+)
+
+const (
+	// Motion units after power on or ESC @, in units per inch
+	DEFAULT_HORIZONTAL_MOTION_UNITS = 200
+	DEFAULT_VERTICAL_MOTION_UNITS   = 400
+
+	MM_PER_INCH   = 25.4
+	DOTS_PER_INCH = 203.2 // Print head resolution, 8 dots per millimetre
+)
+
+// A distance on paper
+// Converted to the motion units set by SetMotionUnits when the command is
+// sent.
+type Length interface {
+	// Returns the length in 1/unitsPerInch inches
+	motionUnits(unitsPerInch int) int
+}
+
+// A length in print head dots, rounded to the nearest motion unit
+// Raster images are measured in dots as is.
+type Dots int
+
+// A length in millimetres, rounded to the nearest motion unit
+type Millimetres float64
+
+func (d Dots) motionUnits(unitsPerInch int) int {
+	return int(math.Round(float64(d) * float64(unitsPerInch) / DOTS_PER_INCH))
+}
+
+func (mm Millimetres) motionUnits(unitsPerInch int) int {
+	return int(math.Round(float64(mm) * float64(unitsPerInch) / MM_PER_INCH))
+}
+
+// Records the motion units set by GS P, 0 selects the default
+func (c *conn) setMotionUnits(x, y uint8) {
+	c.unitsX, c.unitsY = DEFAULT_HORIZONTAL_MOTION_UNITS, DEFAULT_VERTICAL_MOTION_UNITS
+
+	if x != 0 {
+		c.unitsX = int(x)
+	}
+
+	if y != 0 {
+		c.unitsY = int(y)
+	}
+}
+
+// Converts parameter param of command to horizontal motion units within
+// [min, max]
+func (c *conn) horizontal(command, param string, l Length, min, max int) (int, error) {
+	return inRange(command, param, l, l.motionUnits(c.unitsX), min, max)
+}
+
+// Converts parameter param of command to vertical motion units within
+// [min, max]
+func (c *conn) vertical(command, param string, l Length, min, max int) (int, error) {
+	return inRange(command, param, l, l.motionUnits(c.unitsY), min, max)
+}
+
+func inRange(command, param string, l Length, n, min, max int) (int, error) {
+	if n < min || n > max {
+		allowed := fmt.Sprintf("%d to %d motion units", min, max)
+		return 0, argError(command, param, l, allowed, ErrLengthOutOfRange)
+	}
+
+	return n, nil
+}
+
+// Splits n into its low and high bytes, negative values are sent in two's
+// complement
+func split(n int) (uint8, uint8) {
+	return uint8(n), uint8(n >> 8)
+}
+
+// Set absolute print position from the beginning of the line
+func (p *Driver) SetAbsolutePrintPositionTo(x Length) error {
+	return p.Job(func(d *Driver) error {
+		n, err := d.horizontal("SetAbsolutePrintPositionTo", "x", x, 0, math.MaxUint16)
+		if err != nil {
+			return err
+		}
+
+		return d.SetAbsolutePrintPosition(split(n))
+	})
+}
+
+// Moves the print position from the current position, to the left when dx
+// is negative
+func (p *Driver) SetRelativePrintPositionBy(dx Length) error {
+	return p.Job(func(d *Driver) error {
+		n, err := d.horizontal("SetRelativePrintPositionBy", "dx", dx, math.MinInt16, math.MaxInt16)
+		if err != nil {
+			return err
+		}
+
+		return d.SetRelativePrintPosition(split(n))
+	})
+}
+
+// Set left margin
+func (p *Driver) SetLeftMarginTo(margin Length) error {
+	return p.Job(func(d *Driver) error {
+		n, err := d.horizontal("SetLeftMarginTo", "margin", margin, 0, math.MaxUint16)
+		if err != nil {
+			return err
+		}
+
+		return d.SetLeftMargin(split(n))
+	})
+}
+
+// Set printing area width
+func (p *Driver) SetPrintingAreaWidthTo(width Length) error {
+	return p.Job(func(d *Driver) error {
+		n, err := d.horizontal("SetPrintingAreaWidthTo", "width", width, 0, math.MaxUint16)
+		if err != nil {
+			return err
+		}
+
+		return d.SetPrintingAreaWidth(split(n))
+	})
+}
+
+// Set line spacing
+// 0 <= spacing <= 255 vertical motion units
+func (p *Driver) SetLineSpacingTo(spacing Length) error {
+	return p.Job(func(d *Driver) error {
+		n, err := d.vertical("SetLineSpacingTo", "spacing", spacing, 0, math.MaxUint8)
+		if err != nil {
+			return err
+		}
+
+		return d.SetLineSpacing(uint8(n))
+	})
+}
+
+// Print and feed paper
+// 0 <= feed <= 255 vertical motion units
+func (p *Driver) PrintAndFeedBy(feed Length) error {
+	return p.Job(func(d *Driver) error {
+		n, err := d.vertical("PrintAndFeedBy", "feed", feed, 0, math.MaxUint8)
+		if err != nil {
+			return err
+		}
+
+		return d.PrintAndFeedNDotsLines(uint8(n))
+	})
+}
+
+// Set print area in page mode
+// x, y: Starting position
+// width, height: Size of the printing area, at least 1 motion unit
+func (p *Driver) SetPrintAreaInPageModeTo(x, y, width, height Length) error {
+	return p.Job(func(d *Driver) error {
+		x0, err := d.horizontal("SetPrintAreaInPageModeTo", "x", x, 0, math.MaxUint16)
+		if err != nil {
+			return err
+		}
+
+		y0, err := d.vertical("SetPrintAreaInPageModeTo", "y", y, 0, math.MaxUint16)
+		if err != nil {
+			return err
+		}
+
+		dx, err := d.horizontal("SetPrintAreaInPageModeTo", "width", width, 1, math.MaxUint16)
+		if err != nil {
+			return err
+		}
+
+		dy, err := d.vertical("SetPrintAreaInPageModeTo", "height", height, 1, math.MaxUint16)
+		if err != nil {
+			return err
+		}
+
+		xL, xH := split(x0)
+		yL, yH := split(y0)
+		dxL, dxH := split(dx)
+		dyL, dyH := split(dy)
+
+		return d.SetPrintAreaInPageMode(xL, xH, yL, yH, dxL, dxH, dyL, dyH)
+	})
+}
+
+// Set absolute vertical print position in page mode
+func (p *Driver) SetAbsoluteVerticalPrintPositionInPageModeTo(y Length) error {
+	return p.Job(func(d *Driver) error {
+		n, err := d.vertical("SetAbsoluteVerticalPrintPositionInPageModeTo", "y", y, 0, math.MaxUint16)
+		if err != nil {
+			return err
+		}
+
+		return d.SetAbsoluteVerticalPrintPositionInPageMode(split(n))
+	})
+}
+
+// Set relative vertical print position in page mode, upwards when dy is
+// negative
+func (p *Driver) SetRelativeVerticalPrintPositionInPageModeBy(dy Length) error {
+	return p.Job(func(d *Driver) error {
+		n, err := d.vertical("SetRelativeVerticalPrintPositionInPageModeBy", "dy", dy, math.MinInt16, math.MaxInt16)
+		if err != nil {
+			return err
+		}
+
+		return d.SetRelativeVerticalPrintPositionInPageMode(split(n))
+	})
+}
+
+// Print raster bit image of width x height dots
+// Each row takes (width + 7) / 8 bytes of d, the leftmost dot being the
+// most significant bit.
+// 1 <= width <= 1024, 1 <= height <= 4095
+// The width is also limited to the PrintableDots of the profile.
+func (p *Driver) PrintRasterImage(m uint8, width, height Dots, d []uint8) error {
+	maxWidth := Dots(128 * 8)
+	if p.profile != nil && p.profile.PrintableDots < maxWidth {
+		maxWidth = p.profile.PrintableDots
+	}
+
+	if width < 1 || width > maxWidth {
+		allowed := fmt.Sprintf("1 <= width <= %d", maxWidth)
+		return argError("PrintRasterImage", "width", width, allowed, ErrRasterImageSize)
+	}
+
+	if height < 1 || height > 4095 {
+		return argError("PrintRasterImage", "height", height, "1 <= height <= 4095", ErrRasterImageSize)
+	}
+
+	rowBytes := int(width+7) / 8
+	if len(d) != rowBytes*int(height) {
+		allowed := fmt.Sprintf("len(d) == %d", rowBytes*int(height))
+		return argError("PrintRasterImage", "d", len(d), allowed, ErrRasterImageDataMissing)
+	}
+
+	xL, xH := split(rowBytes)
+	yL, yH := split(int(height))
+
+	return p.PrintRasterBitImage(m, xL, xH, yL, yH, d)
+}
@@ -0,0 +1,50 @@
+package commands_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/cyb3rjerry/rongta-escpos/commands"
+)
+
+func TestLengthsAreConvertedToMotionUnits(t *testing.T) {
+	d, sim := newDriver(t)
+
+	// 200 horizontal and 400 vertical units per inch after power on
+	for _, err := range []error{
+		d.SetLeftMarginTo(commands.Dots(576)),        // 567 units
+		d.SetLeftMarginTo(commands.Millimetres(10)),  // 79 units
+		d.SetLineSpacingTo(commands.Dots(30)),        // 59 units
+		d.SetMotionUnits(100, 0),                     // Half as many horizontal units
+		d.SetPrintingAreaWidthTo(commands.Dots(384)), // 189 units
+	} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := []byte{
+		commands.GS, 'L', 567 % 256, 567 / 256,
+		commands.GS, 'L', 79, 0,
+		commands.ESC, '3', 59,
+		commands.GS, 'P', 100, 0,
+		commands.GS, 'W', 189, 0,
+	}
+	if got := sim.Raw(); !bytes.Equal(got, want) {
+		t.Errorf("sent % x, want % x", got, want)
+	}
+}
+
+func TestLengthOutOfRange(t *testing.T) {
+	d, sim := newDriver(t)
+
+	// 200 dots are 394 vertical units, more than ESC 3 takes
+	if err := d.SetLineSpacingTo(commands.Dots(200)); !errors.Is(err, commands.ErrLengthOutOfRange) {
+		t.Errorf("got %v, want ErrLengthOutOfRange", err)
+	}
+
+	if got := sim.Raw(); len(got) > 0 {
+		t.Errorf("sent % x", got)
+	}
+}
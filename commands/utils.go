@@ -1,8 +1,12 @@
 package commands
 
 import (
+	"bytes"
+	"context"
 	"errors"
+	"fmt"
 	"io"
+	"sync"
 )
 
 type COMMAND []byte
@@ -19,7 +23,8 @@ const (
 	// TypeOfMountedAdditionalFonts PrinterIDInfo = 0x45 // TODO: Currently unsupported, requires experimentation
 
 	PaperStatusOK  PaperStatus = 0x00
-	PaperStatusLow PaperStatus = 0x0C
+	PaperStatusLow PaperStatus = 0x0C // Near end
+	PaperStatusOut PaperStatus = 0x60
 )
 
 var (
@@ -55,16 +60,90 @@ const (
 	GS  = 0x1D // Group separator
 	NUL = 0x00 // Null
 	DC2 = 0x12 // Device control 2
+	DC4 = 0x14 // Device control 4
+	FS  = 0x1C // File separator
 	FF  = 0x0C // Form feed
 )
 
-type Driver struct {
+// Connection state shared by a driver and its views
+type conn struct {
 	rwc io.ReadWriteCloser
+
+	// Buffered mode, see WithBuffering
+	buffered  bool
+	chunkSize int
+	buf       bytes.Buffer
+
+	// Held for a command, or for a whole job
+	sem chan struct{}
+
+	// Closed once the write abandoned by an ended context returns, see do
+	abandoned chan struct{}
+
+	// Motion units in units per inch, see SetMotionUnits
+	unitsX, unitsY int
+
+	profile      *Profile
+	interceptors []Interceptor
+	pacing       *Pacing
+
+	// Background reader, see reader.go
+	rmu            sync.Mutex
+	reading        bool
+	closed         bool
+	done           chan struct{}
+	waiters        []*waiter
+	asb            []byte // ASB frame being received
+	asbGen         int    // Counts the reads, see dispatch
+	subscribers    map[int]chan ASBFrame
+	nextSubscriber int
+}
+
+// A Driver is safe for concurrent use. Each command has exclusive access to
+// the connection while it is sent and while its reply is read, Job extends
+// that to a sequence of commands.
+type Driver struct {
+	*conn
+	ctx    context.Context
+	locked bool // The view belongs to a Job holding the connection
 }
 
+// Optional driver settings, passed to NewDriver
+type Option func(*Driver)
+
 // Initialize a new driver instance
-func NewDriver(rwc io.ReadWriteCloser) *Driver {
-	return &Driver{rwc: rwc}
+func NewDriver(rwc io.ReadWriteCloser, opts ...Option) *Driver {
+	p := &Driver{
+		conn: &conn{
+			rwc:         rwc,
+			sem:         make(chan struct{}, 1),
+			done:        make(chan struct{}),
+			subscribers: map[int]chan ASBFrame{},
+		},
+		ctx: context.Background(),
+	}
+	p.setMotionUnits(0, 0)
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	return p
+}
+
+// Returns a view of the driver whose reads and writes honor the
+// cancellation and deadline of ctx. Both views share the connection.
+func (p *Driver) WithContext(ctx context.Context) *Driver {
+	if ctx == nil {
+		panic("nil context")
+	}
+
+	return &Driver{conn: p.conn, ctx: ctx, locked: p.locked}
+}
+
+// Returns the driver's context, context.Background() unless set through
+// WithContext
+func (p *Driver) Context() context.Context {
+	return p.ctx
 }
 
 // Recovers from a recoverable error and restarts printing from the line where the
@@ -77,8 +156,7 @@ func NewDriver(rwc io.ReadWriteCloser) *Driver {
 // With a parallel interface model, this command can’t be
 // executed when the printer is busy.
 func (p *Driver) RecoverAndRestartPrint() error {
-	_, err := p.rwc.Write([]byte{ESC, ENQ, 0x01})
-	return err
+	return p.exec("RecoverAndRestartPrint", []byte{ESC, ENQ, 0x01})
 }
 
 // Recovers from a recoverable error after clearing the receive and print buffers
@@ -90,8 +168,7 @@ func (p *Driver) RecoverAndRestartPrint() error {
 // With a parallel interface model, this command can’t be
 // executed when the printer is busy.
 func (p *Driver) RecoverAndCancelPrint() error {
-	_, err := p.rwc.Write([]byte{ESC, ENQ, 0x02})
-	return err
+	return p.exec("RecoverAndCancelPrint", []byte{ESC, ENQ, 0x02})
 }
 
 // Generate a pulse at real-time to either pin 2 or pin 5
@@ -102,34 +179,34 @@ func (p *Driver) RecoverAndCancelPrint() error {
 func (p *Driver) SendPulseToPin(m bool, t uint8) error {
 	var pin byte
 	if m {
-		pin = 0x05
-	} else {
-		pin = 0x02
+		pin = 0x01
 	}
 
 	if (t > 0x08) || (t < 0x01) {
-		return ErrInvalidPulseTime
+		return argError("SendPulseToPin", "t", t, "1 <= t <= 8", ErrInvalidPulseTime)
 	}
 
-	_, err := p.rwc.Write([]byte{ESC, BANG, pin, t})
-	return err
+	return p.exec("SendPulseToPin", []byte{DLE, DC4, 0x01, pin, t})
 }
 
 // Set beep prompt
-// Only for page mode and general 347
+// Only on the models with a beeper, see Profile.Beeper
 // n: number of beeps (1 <= n <= 9)
 // t: time of each beep (1 <= t <= 9)
 func (p *Driver) SetBeepPrompt(n, t uint8) error {
 	if n < 1 || n > 9 {
-		return ErrInvalidNumberOfBeeps
+		return argError("SetBeepPrompt", "n", n, "1 <= n <= 9", ErrInvalidNumberOfBeeps)
 	}
 
 	if t < 1 || t > 9 {
-		return ErrInvalidBeepTime
+		return argError("SetBeepPrompt", "t", t, "1 <= t <= 9", ErrInvalidBeepTime)
+	}
+
+	if p.profile != nil && !p.profile.Beeper {
+		return unsupported("SetBeepPrompt")
 	}
 
-	_, err := p.rwc.Write([]byte{ESC, 'B', n, t})
-	return err
+	return p.exec("SetBeepPrompt", []byte{ESC, 'B', n, t})
 }
 
 // Generate pulse
@@ -145,63 +222,57 @@ func (p *Driver) GeneratePulse(m bool, t1, t2 uint8) error {
 		pin = 0x02
 	}
 
-	_, err := p.rwc.Write([]byte{ESC, 'p', pin, t1, t2})
-	return err
+	return p.exec("GeneratePulse", []byte{ESC, 'p', pin, t1, t2})
 }
 
 // Disable/Enable pannel buttons.
 // When the LSB of n is 0, the panel buttons are enabled.
 // When the LSB of n is 1, the panel buttons are disabled.
 func (p *Driver) DisablePanelButtons(n uint8) error {
-	_, err := p.rwc.Write([]byte{ESC, 'c', '5', n})
-	return err
+	return p.exec("DisablePanelButtons", []byte{ESC, 'c', '5', n})
 }
 
 // Cut paper (only partial is supported)
 func (p *Driver) Cut() error {
-	_, err := p.rwc.Write([]byte{ESC, 'i'})
-	return err
+	if p.profile != nil && !p.profile.Cutter {
+		return unsupported("Cut")
+	}
+
+	return p.exec("Cut", []byte{ESC, 'i'})
 }
 
 // Transmit printer ID
-// n = 1: Transmit the printer ID
-// n = 2: Transmit the printer ID and the firmware version
+// n = 1: Printer model ID
+// n = 2: Printer type ID
 // n = 65: Firmware version
-// n = 66: Printer ID
+// n = 66: Manufacturer
+// n = 67: Printer name
+// n = 68: Serial number
+// IDs 1 and 2 are a single byte, the others a block framed by 0x5F and NUL
+// which is returned as is. See Identify for the decoded IDs.
 func (p *Driver) TransmitPrinterID(n PrinterIDInfo) ([]byte, error) {
-	buf := make([]byte, 1024)
-
 	switch n {
-	case PrinterModelID | PrinterTypeID:
-		_, err := p.rwc.Write([]byte{ESC, 'i', 1})
-		if err != nil {
-			return []byte{}, err
-		}
-
-		// Read the response
-		bytesRead, err := p.rwc.Read(buf)
-		if err != nil {
-			return []byte{}, err
-		}
-
-		return buf[:bytesRead], nil
+	case PrinterModelID, PrinterTypeID, FirmwareVersion, ManufacturerID, PrinterName, SerialNumber:
+	default:
+		return []byte{}, argError("TransmitPrinterID", "n", n, "1, 2 or 65 to 68", ErrInvalidTypePrinterID)
+	}
 
-	case FirmwareVersion | ManufacturerID | PrinterName | SerialNumber:
-		bytesRead, err := p.rwc.Write([]byte{ESC, 'i', 2})
-		if err != nil {
-			return []byte{}, err
-		}
+	frame := blockFrame
+	if n == PrinterModelID || n == PrinterTypeID {
+		frame = fixedFrame(1)
+	}
 
-		return buf[:bytesRead], nil
-	default:
-		return []byte{}, ErrInvalidTypePrinterID
+	reply, err := p.query("TransmitPrinterID", []byte{GS, 'I', uint8(n)}, frame)
+	if err != nil {
+		return []byte{}, err
 	}
+
+	return reply, nil
 }
 
 // Toggle macro definition
 func (p *Driver) ToggleMacroDefinition() error {
-	_, err := p.rwc.Write([]byte{GS, ':'})
-	return err
+	return p.exec("ToggleMacroDefinition", []byte{GS, ':'})
 }
 
 // Execute macro
@@ -214,34 +285,36 @@ func (p *Driver) ToggleMacroDefinition() error {
 // macro once. The printer repeats the operation r times.
 // The waiting time is t x 100ms.
 func (p *Driver) ExecuteMacro(r, t, m uint8) error {
-	_, err := p.rwc.Write([]byte{GS, '^', r, t, m})
-	return err
+	return p.exec("ExecuteMacro", []byte{GS, '^', r, t, m})
 }
 
 // Toggle ASB
-// Bit 0: Undefined
-// Bit 1: Undefined
+// Bit 0: 0 = Drawer status disabled, 1: Drawer status enabled
+// Bit 1: 0 = Online/offline status disabled, 2: Online/offline status enabled
 // Bit 2: 0 = Error status disabled, 4: Error status enabled
 // Bit 3: 0 = Paper sensor disabled, 8: Paper sensor enabled
 // Bit 4-7: Undefined
-// TODO: Define types for the bits
+// See SetASB for the typed flags
 func (p *Driver) ToggleASB(n uint8) error {
-	_, err := p.rwc.Write([]byte{GS, 'a', n})
-	return err
+	return p.exec("ToggleASB", []byte{GS, 'a', n})
 }
 
 // Transmit status
+// Returns the paper sensor status. Unlike DLE EOT 4, GS r is processed in
+// order with the print data.
 func (p *Driver) TransmitStatus() (PaperStatus, error) {
-	_, err := p.rwc.Write([]byte{GS, 'r', 1})
+	// Read status
+	reply, err := p.query("TransmitStatus", []byte{GS, 'r', 1}, fixedFrame(1))
 	if err != nil {
 		return PaperStatusLow, err
 	}
 
-	// Read status
-	buf := make([]byte, 1)
-	_, err = p.rwc.Read(buf)
+	if reply[0]&PAPER_SENSOR_FIXED_MASK != 0 {
+		err := fmt.Errorf("%w: GS r 1 replied %#02x", ErrInvalidStatusReply, reply[0])
+		return PaperStatusLow, protocolError("TransmitStatus", err)
+	}
 
-	return PaperStatus(buf[0] & 0x0C), err
+	return paperSensorStatus(reply[0]), nil
 }
 
 // Set horizontal and vertical motion units
@@ -249,23 +322,28 @@ func (p *Driver) TransmitStatus() (PaperStatus, error) {
 // and 1 / y inches, respectively. The default value are x = 200 and y
 // = 400. When x and y are set to 0, the default setting of each value
 // is used.
+// The units are used to convert the Length of the typed methods.
 func (p *Driver) SetMotionUnits(x, y uint8) error {
-	_, err := p.rwc.Write([]byte{GS, 'P', x, y})
-	return err
+	return p.exclusive(func() error {
+		err := p.command("SetMotionUnits", []byte{GS, 'P', x, y})
+		if err == nil {
+			p.setMotionUnits(x, y)
+		}
+
+		return err
+	})
 }
 
 // Print test page
 func (p *Driver) PrintTestPage() error {
-	_, err := p.rwc.Write([]byte{DC2, 'T'})
-	return err
+	return p.exec("PrintTestPage", []byte{DC2, 'T'})
 }
 
 // Set peripheral device
 // bit 0: 0 = Printer disable, 1 = Printer enable
 // bit 1-7: Undefined
 func (p *Driver) SetPeripheralDevice(n uint8) error {
-	_, err := p.rwc.Write([]byte{ESC, '=', n})
-	return err
+	return p.exec("SetPeripheralDevice", []byte{ESC, '=', n})
 }
 
 // Feed marked paper to print starting position
@@ -276,8 +354,7 @@ func (p *Driver) SetPeripheralDevice(n uint8) error {
 // the marked paper, the printer does not feed the marked paper to
 // the next print starting position.
 func (p *Driver) FeedMarkedPaper() error {
-	_, err := p.rwc.Write([]byte{GS, FF})
-	return err
+	return p.exec("FeedMarkedPaper", []byte{GS, FF})
 }
 
 // [UNIMPLEMENTED] Doc is insanely unclear.
@@ -292,8 +369,7 @@ func (p *Driver) FeedMarkedPaper() error {
 // pH = ????: Undocumented
 func (p *Driver) ExecuteTestPrint(n, m, pL, pH uint8) error {
 	panic("unimplemented")
-	_, err := p.rwc.Write([]byte{GS, '(', n, m, pL, pH})
-	return err
+	return p.exec("ExecuteTestPrint", []byte{GS, '(', n, m, pL, pH})
 }
 
 // Select counter print mode (serial number counter)
@@ -302,11 +378,10 @@ func (p *Driver) ExecuteTestPrint(n, m, pL, pH uint8) error {
 // n = 2: Adds spaces to the right
 func (p *Driver) SelectCounterPrintMode(n uint8) error {
 	if n > 2 {
-		return ErrInvalidCounterPrintMode
+		return argError("SelectCounterPrintMode", "n", n, "0 <= n <= 2", ErrInvalidCounterPrintMode)
 	}
 
-	_, err := p.rwc.Write([]byte{GS, 'C', '0', n})
-	return err
+	return p.exec("SelectCounterPrintMode", []byte{GS, 'C', '0', n})
 }
 
 // Selects a count mode for the serial number counter
@@ -314,22 +389,19 @@ func (p *Driver) SelectCounterPrintMode(n uint8) error {
 // n: Specifies the stepping amount when counting up or down
 // r: Specifies the repetition number when the counter value is fixed
 func (p *Driver) SelectCountMode(al, aH, bL, bH, n, r uint8) error {
-	_, err := p.rwc.Write([]byte{GS, 'C', '1', al, aH, bL, bH, n, r})
-	return err
+	return p.exec("SelectCountMode", []byte{GS, 'C', '1', al, aH, bL, bH, n, r})
 }
 
 // Sets the serial number counter value
 // nL, nH: Sets the value of the serial number counter
 // set by (nL + nH x 256)
 func (p *Driver) SetCounterValue(nL, nH uint8) error {
-	_, err := p.rwc.Write([]byte{GS, 'C', '2', nL, nH})
-	return err
+	return p.exec("SetCounterValue", []byte{GS, 'C', '2', nL, nH})
 }
 
 // Print counter
 // Sets the serial counter value in the print buffer and increments
 // or decrements the counter value
 func (p *Driver) PrintCounter() error {
-	_, err := p.rwc.Write([]byte{GS, 'c', '3'})
-	return err
+	return p.exec("PrintCounter", []byte{GS, 'c', '3'})
 }
@@ -0,0 +1,42 @@
+package commands_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/cyb3rjerry/rongta-escpos/commands"
+)
+
+// Returns the bytes sent by call, or its error
+func encode(call func(d *commands.Driver) error) ([]byte, error) {
+	d := commands.NewBuffer()
+	if err := call(d); err != nil {
+		return nil, err
+	}
+
+	return d.Bytes(), nil
+}
+
+func TestSendPulseToPin(t *testing.T) {
+	for _, tt := range []struct {
+		pin5 bool
+		time uint8
+		want []byte
+		err  error
+	}{
+		{false, 1, []byte{commands.DLE, commands.DC4, 1, 0, 1}, nil},
+		{true, 8, []byte{commands.DLE, commands.DC4, 1, 1, 8}, nil},
+		{true, 0, nil, commands.ErrInvalidPulseTime},
+		{false, 9, nil, commands.ErrInvalidPulseTime},
+	} {
+		got, err := encode(func(d *commands.Driver) error { return d.SendPulseToPin(tt.pin5, tt.time) })
+		if !errors.Is(err, tt.err) {
+			t.Errorf("SendPulseToPin(%t, %d) returned %v, want %v", tt.pin5, tt.time, err, tt.err)
+		}
+
+		if !bytes.Equal(got, tt.want) {
+			t.Errorf("SendPulseToPin(%t, %d) sent % x, want % x", tt.pin5, tt.time, got, tt.want)
+		}
+	}
+}
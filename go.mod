@@ -3,7 +3,8 @@ module github.com/cyb3rjerry/rongta-escpos
 go 1.22.4
 
 require (
-	github.com/creack/goselect v0.1.2 // indirect
-	go.bug.st/serial v1.6.2 // indirect
-	golang.org/x/sys v0.0.0-20220829200755-d48e67d00261 // indirect
+	go.bug.st/serial v1.6.2
+	golang.org/x/sys v0.0.0-20220829200755-d48e67d00261
 )
+
+require github.com/creack/goselect v0.1.2 // indirect
@@ -0,0 +1,99 @@
+// Package replies holds the bytes a fake printer sends back to the host,
+// shared by rongtasim and commandstest.
+package replies
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Replies waiting to be read by the host
+// Reads block until a reply is available, the read deadline expires or the
+// queue is closed.
+type Queue struct {
+	mu           sync.Mutex
+	out          bytes.Buffer
+	wake         chan struct{}
+	readDeadline time.Time
+	closed       bool
+}
+
+func NewQueue() *Queue {
+	return &Queue{wake: make(chan struct{})}
+}
+
+// Queues b for the host
+func (q *Queue) Push(b ...byte) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.out.Write(b)
+	q.notify()
+}
+
+func (q *Queue) Read(b []byte) (int, error) {
+	for {
+		q.mu.Lock()
+
+		if q.out.Len() > 0 {
+			n, err := q.out.Read(b)
+			q.mu.Unlock()
+			return n, err
+		}
+
+		if q.closed {
+			q.mu.Unlock()
+			return 0, io.EOF
+		}
+
+		deadline := q.readDeadline
+		wake := q.wake
+		q.mu.Unlock()
+
+		if deadline.IsZero() {
+			<-wake
+			continue
+		}
+
+		d := time.Until(deadline)
+		if d <= 0 {
+			return 0, os.ErrDeadlineExceeded
+		}
+
+		timer := time.NewTimer(d)
+		select {
+		case <-wake:
+			timer.Stop()
+		case <-timer.C:
+			return 0, os.ErrDeadlineExceeded
+		}
+	}
+}
+
+// Ends blocked and later reads once the queued replies are read
+func (q *Queue) Close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.closed = true
+	q.notify()
+}
+
+func (q *Queue) SetReadDeadline(t time.Time) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.readDeadline = t
+	q.notify()
+
+	return nil
+}
+
+// Wakes up blocked readers, must be called with the lock held
+func (q *Queue) notify() {
+	close(q.wake)
+	q.wake = make(chan struct{})
+}
@@ -0,0 +1,265 @@
+package rongta
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+var (
+	ErrReplayMismatch = errors.New("written data doesn't match the capture")
+)
+
+type Direction string
+
+const (
+	DirWrite Direction = "write" // Host to printer
+	DirRead  Direction = "read"  // Printer to host
+)
+
+// One read or write of a captured session
+// Captures are stored as one JSON record per line.
+type CaptureRecord struct {
+	Time time.Time `json:"time"`
+	Dir  Direction `json:"dir"`
+	Data []byte    `json:"data"`
+}
+
+// Records every write and read going through a connection
+type captureConn struct {
+	rwc io.ReadWriteCloser
+	rec *recorder
+}
+
+// Writes the records of one or more connections to a single capture
+type recorder struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// Wraps rwc so every write and read is recorded to w
+func NewCapture(rwc io.ReadWriteCloser, w io.Writer) io.ReadWriteCloser {
+	return &captureConn{rwc: rwc, rec: &recorder{enc: json.NewEncoder(w)}}
+}
+
+// Record the session to w, see NewCapture
+// Every connection the printer opens is recorded: with WithReconnect, the
+// ESC @ and setup sent on a new connection are part of the capture.
+func WithCapture(w io.Writer) Option {
+	return func(p *Printer) {
+		p.capture = w
+	}
+}
+
+// Wraps the connections returned by connect so they are all recorded to
+// the same capture
+func captureConnections(connect func() (io.ReadWriteCloser, error), w io.Writer) func() (io.ReadWriteCloser, error) {
+	rec := &recorder{enc: json.NewEncoder(w)}
+
+	return func() (io.ReadWriteCloser, error) {
+		rwc, err := connect()
+		if err != nil {
+			return nil, err
+		}
+
+		return &captureConn{rwc: rwc, rec: rec}, nil
+	}
+}
+
+func (c *captureConn) Write(b []byte) (int, error) {
+	n, err := c.rwc.Write(b)
+	c.rec.record(DirWrite, b[:n])
+
+	return n, err
+}
+
+func (c *captureConn) Read(b []byte) (int, error) {
+	n, err := c.rwc.Read(b)
+	c.rec.record(DirRead, b[:n])
+
+	return n, err
+}
+
+func (c *captureConn) Close() error {
+	return c.rwc.Close()
+}
+
+func (c *captureConn) SetReadDeadline(t time.Time) error {
+	return setReadDeadline(c.rwc, t)
+}
+
+func (c *captureConn) SetWriteDeadline(t time.Time) error {
+	return setWriteDeadline(c.rwc, t)
+}
+
+// Failing to record doesn't fail the session
+func (r *recorder) record(dir Direction, b []byte) {
+	if len(b) == 0 {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.enc.Encode(CaptureRecord{
+		Time: time.Now(),
+		Dir:  dir,
+		Data: append([]byte(nil), b...),
+	})
+}
+
+// Parses a capture written by NewCapture
+func ReadCapture(r io.Reader) ([]CaptureRecord, error) {
+	var records []CaptureRecord
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 16*1024*1024)
+
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+
+		var record CaptureRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, fmt.Errorf("capture line %d: %w", line, err)
+		}
+
+		if record.Dir != DirWrite && record.Dir != DirRead {
+			return nil, fmt.Errorf("capture line %d: invalid direction %q", line, record.Dir)
+		}
+
+		records = append(records, record)
+	}
+
+	return records, scanner.Err()
+}
+
+// Sends the captured writes to w, a real printer or the emulator
+// When realtime is set, the delays between writes are reproduced.
+func Replay(w io.Writer, records []CaptureRecord, realtime bool) error {
+	var last time.Time
+
+	for _, record := range records {
+		if record.Dir != DirWrite {
+			continue
+		}
+
+		if realtime && !last.IsZero() {
+			time.Sleep(record.Time.Sub(last))
+		}
+		last = record.Time
+
+		if _, err := w.Write(record.Data); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Fake device playing back a capture
+// Recorded replies become readable once the writes that preceded them in
+// the capture have been received. With Strict set, writes must match the
+// capture byte for byte.
+type ScriptedDevice struct {
+	Strict bool
+
+	mu       sync.Mutex
+	records  []CaptureRecord
+	received []byte // Written but not matched to a record yet
+	out      bytes.Buffer
+	wake     chan struct{}
+	closed   bool
+}
+
+func NewScriptedDevice(records []CaptureRecord) *ScriptedDevice {
+	d := &ScriptedDevice{
+		records: records,
+		wake:    make(chan struct{}),
+	}
+	d.advance()
+
+	return d
+}
+
+func (d *ScriptedDevice) Write(b []byte) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.closed {
+		return 0, ErrClosed
+	}
+
+	d.received = append(d.received, b...)
+
+	for len(d.records) > 0 && d.records[0].Dir == DirWrite {
+		expected := d.records[0].Data
+		if len(d.received) < len(expected) {
+			if d.Strict && !bytes.HasPrefix(expected, d.received) {
+				return 0, fmt.Errorf("%w: got % x, want % x", ErrReplayMismatch, d.received, expected)
+			}
+			break
+		}
+
+		if d.Strict && !bytes.Equal(d.received[:len(expected)], expected) {
+			return 0, fmt.Errorf("%w: got % x, want % x", ErrReplayMismatch, d.received[:len(expected)], expected)
+		}
+
+		d.received = d.received[len(expected):]
+		d.records = d.records[1:]
+		d.advance()
+	}
+
+	return len(b), nil
+}
+
+// Returns the recorded replies, io.EOF once the capture is exhausted
+func (d *ScriptedDevice) Read(b []byte) (int, error) {
+	for {
+		d.mu.Lock()
+
+		if d.out.Len() > 0 {
+			n, err := d.out.Read(b)
+			d.mu.Unlock()
+			return n, err
+		}
+
+		if d.closed || len(d.records) == 0 {
+			d.mu.Unlock()
+			return 0, io.EOF
+		}
+
+		wake := d.wake
+		d.mu.Unlock()
+
+		<-wake
+	}
+}
+
+func (d *ScriptedDevice) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.closed = true
+	close(d.wake)
+	d.wake = make(chan struct{})
+
+	return nil
+}
+
+// Queues the reads that are due, must be called with the lock held
+func (d *ScriptedDevice) advance() {
+	for len(d.records) > 0 && d.records[0].Dir == DirRead {
+		d.out.Write(d.records[0].Data)
+		d.records = d.records[1:]
+	}
+
+	close(d.wake)
+	d.wake = make(chan struct{})
+}
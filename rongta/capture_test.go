@@ -0,0 +1,266 @@
+package rongta
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cyb3rjerry/rongta-escpos/commands"
+	"github.com/cyb3rjerry/rongta-escpos/rongtasim"
+)
+
+// Prints a short receipt and reads the autocutter status on the way
+func session(t *testing.T, rwc io.ReadWriteCloser) bool {
+	t.Helper()
+
+	d := commands.NewDriver(rwc)
+
+	if err := d.SetJustification(commands.JustifyCenter); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := d.WriteStringToBuffer("hello\n"); err != nil {
+		t.Fatal(err)
+	}
+
+	jammed, err := d.GetAutocutterStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := d.Cut(); err != nil {
+		t.Fatal(err)
+	}
+
+	return jammed
+}
+
+// Concatenates the data of the records going in dir
+func captured(records []CaptureRecord, dir Direction) []byte {
+	var b []byte
+	for _, record := range records {
+		if record.Dir == dir {
+			b = append(b, record.Data...)
+		}
+	}
+
+	return b
+}
+
+func recordSession(t *testing.T) (*rongtasim.Printer, []CaptureRecord) {
+	t.Helper()
+
+	sim := rongtasim.New()
+	sim.SetStatus(3, 0x1A) // Autocutter error
+
+	var capture bytes.Buffer
+	if !session(t, NewCapture(sim, &capture)) {
+		t.Error("autocutter reported working")
+	}
+	sim.Close()
+
+	records, err := ReadCapture(&capture)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return sim, records
+}
+
+func TestCaptureRecordsSession(t *testing.T) {
+	sim, records := recordSession(t)
+
+	if written := captured(records, DirWrite); !bytes.Equal(written, sim.Raw()) {
+		t.Errorf("captured writes % x, the printer received % x", written, sim.Raw())
+	}
+
+	if read := captured(records, DirRead); !bytes.Equal(read, []byte{0x1A}) {
+		t.Errorf("captured reads % x, want the status reply 1a", read)
+	}
+
+	for i, record := range records {
+		if record.Time.IsZero() || len(record.Data) == 0 {
+			t.Errorf("record %d: %+v", i, record)
+		}
+	}
+}
+
+func TestCaptureRecordsReconnection(t *testing.T) {
+	connector := &fakeConnector{}
+	var capture bytes.Buffer
+
+	p, err := New(fakeConfig{connector},
+		WithCapture(&capture),
+		WithReconnect(ReconnectPolicy{InitialBackoff: time.Millisecond}),
+		WithSetup(func(d *commands.Driver) error {
+			return d.SetJustification(commands.JustifyCenter)
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	// The printer goes away
+	connector.last().Close()
+
+	if err := p.Println("after"); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := ReadCapture(&capture)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The new connection's ESC @ and setup are captured too
+	want := []byte("\x1b@\x1ba\x01after\n\x1bd\x0a")
+	if written := captured(records, DirWrite); !bytes.Equal(written, want) {
+		t.Errorf("captured writes % x, want % x", written, want)
+	}
+
+	if received := connector.last().bytes(); !bytes.Equal(received, want) {
+		t.Errorf("the printer received % x, want % x", received, want)
+	}
+}
+
+func TestReplay(t *testing.T) {
+	sim, records := recordSession(t)
+
+	var replayed bytes.Buffer
+	if err := Replay(&replayed, records, false); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(replayed.Bytes(), sim.Raw()) {
+		t.Errorf("replayed % x, want % x", replayed.Bytes(), sim.Raw())
+	}
+}
+
+func TestReplayRealtime(t *testing.T) {
+	start := time.Now()
+	records := []CaptureRecord{
+		{Time: start, Dir: DirWrite, Data: []byte("a")},
+		{Time: start.Add(10 * time.Millisecond), Dir: DirRead, Data: []byte{0x12}},
+		{Time: start.Add(50 * time.Millisecond), Dir: DirWrite, Data: []byte("b")},
+	}
+
+	var replayed bytes.Buffer
+	if err := Replay(&replayed, records, true); err != nil {
+		t.Fatal(err)
+	}
+
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("replayed in %s, want the 50ms between the writes", elapsed)
+	}
+
+	if replayed.String() != "ab" {
+		t.Errorf("replayed %q, want \"ab\"", replayed.String())
+	}
+}
+
+func TestReadCaptureErrors(t *testing.T) {
+	for _, capture := range []string{
+		`{"time":"2024-01-01T00:00:00Z","dir":"write","data":"aGk="}` + "\nnot json\n",
+		`{"time":"2024-01-01T00:00:00Z","dir":"sideways","data":"aGk="}` + "\n",
+	} {
+		if _, err := ReadCapture(strings.NewReader(capture)); err == nil || !strings.Contains(err.Error(), "capture line") {
+			t.Errorf("%q: got %v, want an error locating the line", capture, err)
+		}
+	}
+
+	records, err := ReadCapture(strings.NewReader("\n" + `{"time":"2024-01-01T00:00:00Z","dir":"read","data":"aGk="}` + "\n\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(records) != 1 || string(records[0].Data) != "hi" {
+		t.Errorf("got %+v, want the single record", records)
+	}
+}
+
+func TestScriptedDevicePlaysSessionBack(t *testing.T) {
+	_, records := recordSession(t)
+
+	device := NewScriptedDevice(records)
+	device.Strict = true
+
+	if !session(t, device) {
+		t.Error("recorded autocutter status not played back")
+	}
+	device.Close()
+}
+
+func TestScriptedDeviceReplies(t *testing.T) {
+	device := NewScriptedDevice([]CaptureRecord{
+		{Dir: DirWrite, Data: []byte("A")},
+		{Dir: DirRead, Data: []byte{0x12}},
+		{Dir: DirWrite, Data: []byte("BC")},
+		{Dir: DirRead, Data: []byte{0x16}},
+	})
+
+	buf := make([]byte, 8)
+
+	device.Write([]byte("A"))
+	if n, err := device.Read(buf); err != nil || !bytes.Equal(buf[:n], []byte{0x12}) {
+		t.Fatalf("got % x, %v, want 12", buf[:n], err)
+	}
+
+	// The reply is due once the whole write came in
+	device.Write([]byte("B"))
+
+	read := make(chan []byte, 1)
+	go func() {
+		n, _ := device.Read(buf)
+		read <- buf[:n]
+	}()
+
+	select {
+	case b := <-read:
+		t.Fatalf("read % x before the write completed", b)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	device.Write([]byte("C"))
+	if b := <-read; !bytes.Equal(b, []byte{0x16}) {
+		t.Errorf("got % x, want 16", b)
+	}
+
+	if _, err := device.Read(buf); err != io.EOF {
+		t.Errorf("got %v once the capture is exhausted, want io.EOF", err)
+	}
+}
+
+func TestScriptedDeviceStrict(t *testing.T) {
+	records := []CaptureRecord{
+		{Dir: DirWrite, Data: []byte("AB")},
+		{Dir: DirRead, Data: []byte{0x12}},
+	}
+
+	strict := NewScriptedDevice(records)
+	strict.Strict = true
+
+	if _, err := strict.Write([]byte("X")); !errors.Is(err, ErrReplayMismatch) {
+		t.Errorf("partial write: got %v, want ErrReplayMismatch", err)
+	}
+
+	strict = NewScriptedDevice(records)
+	strict.Strict = true
+
+	if _, err := strict.Write([]byte("AX")); !errors.Is(err, ErrReplayMismatch) {
+		t.Errorf("whole write: got %v, want ErrReplayMismatch", err)
+	}
+
+	// Without Strict, only the lengths count
+	loose := NewScriptedDevice(records)
+	if _, err := loose.Write([]byte("XY")); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := make([]byte, 8)
+	if n, err := loose.Read(buf); err != nil || !bytes.Equal(buf[:n], []byte{0x12}) {
+		t.Errorf("got % x, %v, want 12", buf[:n], err)
+	}
+}
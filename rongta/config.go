@@ -1,8 +1,12 @@
 package rongta
 
 import (
+	"errors"
 	"io"
+	"net"
 	"runtime"
+	"strconv"
+	"time"
 
 	"go.bug.st/serial"
 )
@@ -15,8 +19,23 @@ const (
 	TCP
 )
 
+// Serial flow control
+type FlowControl int
+
+const (
+	NoFlowControl      FlowControl = iota
+	RTSCTSFlowControl              // Hardware, RTS/CTS lines
+	XONXOFFFlowControl             // Software, XON/XOFF characters
+)
+
+var (
+	ErrInvalidFlowControl     = errors.New("invalid flow control")
+	ErrFlowControlUnsupported = errors.New("serial flow control is only supported on Linux")
+)
+
 type Config interface {
 	Default()
+	String() string // Connection URI, see ParseURI
 	connect() (io.ReadWriteCloser, error)
 }
 
@@ -26,6 +45,10 @@ type SerialConfig struct {
 	Parity   serial.Parity
 	DataBits int
 	StopBits serial.StopBits
+
+	// Pauses the data when the printer's receive buffer fills up. Large
+	// raster images need it on slow links, see also commands.WithPacing.
+	FlowControl FlowControl
 }
 
 func (c *SerialConfig) Default() {
@@ -33,6 +56,7 @@ func (c *SerialConfig) Default() {
 	c.Parity = serial.NoParity
 	c.DataBits = 8
 	c.StopBits = serial.OneStopBit
+	c.FlowControl = NoFlowControl
 
 	switch runtime.GOOS {
 	case "windows":
@@ -52,7 +76,7 @@ func (c *SerialConfig) connect() (io.ReadWriteCloser, error) {
 		StopBits: c.StopBits,
 	}
 
-	s, err := serial.Open(c.Port, mode)
+	s, err := openSerial(c, mode)
 	if err != nil {
 		return nil, err
 	}
@@ -60,28 +84,69 @@ func (c *SerialConfig) connect() (io.ReadWriteCloser, error) {
 	return s, nil
 }
 
-// Unimplemented
+// USB printer-class device (usblp on Linux, /dev/usb/lpN)
+// When Device is empty, the first printer matching VendorID, ProductID and
+// Serial is looked up through sysfs. Zero values match any device.
 type USBConfig struct {
-	// Todo
+	Device       string
+	VendorID     uint16
+	ProductID    uint16
+	Serial       string
+	SysfsRoot    string // Defaults to /sys
+	DevRoot      string // Defaults to /dev/usb
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
 }
 
 func (c *USBConfig) Default() {
-	panic("unimplemented")
-}
-
-func (c *USBConfig) connect() (io.ReadWriteCloser, error) {
-	panic("unimplemented")
+	c.Device = ""
+	c.SysfsRoot = "/sys"
+	c.DevRoot = "/dev/usb"
+	c.ReadTimeout = 5 * time.Second
+	c.WriteTimeout = 10 * time.Second
 }
 
-// Unimplemented
+// Raw TCP connection to a network printer (port 9100)
 type TCPConfig struct {
-	// Todo
+	Host         string
+	Port         int
+	DialTimeout  time.Duration
+	ReadTimeout  time.Duration // Zero disables the read deadline
+	WriteTimeout time.Duration // Zero disables the write deadline
+	KeepAlive    time.Duration // Negative disables TCP keepalive
 }
 
 func (c *TCPConfig) Default() {
-	panic("unimplemented")
+	// Factory IP address of the Ethernet interface
+	c.Host = "192.168.123.100"
+	c.Port = 9100
+	c.DialTimeout = 5 * time.Second
+	c.ReadTimeout = 5 * time.Second
+	c.WriteTimeout = 10 * time.Second
+	c.KeepAlive = 30 * time.Second
 }
 
+// Connect to the printer's raw port
+// Returns a ReadWriteCloser interface
 func (c *TCPConfig) connect() (io.ReadWriteCloser, error) {
-	panic("unimplemented")
+	port := c.Port
+	if port == 0 {
+		port = 9100
+	}
+
+	dialer := &net.Dialer{
+		Timeout:   c.DialTimeout,
+		KeepAlive: c.KeepAlive,
+	}
+
+	conn, err := dialer.Dial("tcp", net.JoinHostPort(c.Host, strconv.Itoa(port)))
+	if err != nil {
+		return nil, err
+	}
+
+	return &deadlineConn{
+		conn:         conn,
+		readTimeout:  c.ReadTimeout,
+		writeTimeout: c.WriteTimeout,
+	}, nil
 }
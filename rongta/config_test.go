@@ -0,0 +1,268 @@
+package rongta
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cyb3rjerry/rongta-escpos/commands"
+)
+
+// Printer on a local TCP port recording what it receives and answering
+// DLE EOT n with status[n]
+type fakePrinter struct {
+	mu       sync.Mutex
+	received []byte
+	scanned  int // Bytes checked for status requests
+	status   [5]byte
+}
+
+func (f *fakePrinter) serve(conn net.Conn) {
+	defer conn.Close()
+
+	b := make([]byte, 256)
+	for {
+		n, err := conn.Read(b)
+		if err != nil {
+			return
+		}
+
+		f.mu.Lock()
+		f.received = append(f.received, b[:n]...)
+
+		// Requests split across reads are answered once complete
+		var replies []byte
+		for f.scanned+3 <= len(f.received) {
+			request := f.received[f.scanned : f.scanned+3]
+			if request[0] == commands.DLE && request[1] == commands.EOT && request[2] >= 1 && request[2] <= 4 {
+				replies = append(replies, f.status[request[2]])
+				f.scanned += 3
+				continue
+			}
+			f.scanned++
+		}
+		f.mu.Unlock()
+
+		if len(replies) > 0 {
+			conn.Write(replies)
+		}
+	}
+}
+
+func (f *fakePrinter) bytes() []byte {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return bytes.Clone(f.received)
+}
+
+// Serves a fake printer on a local TCP port
+func listenPrinter(t *testing.T, addr string) (*fakePrinter, *TCPConfig) {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		t.Skipf("can't listen on %s: %v", addr, err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	// Idle, every status reply has only its fixed bits set
+	printer := &fakePrinter{}
+	for n := range printer.status {
+		printer.status[n] = commands.REALTIME_STATUS_FIXED_BITS
+	}
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+
+		printer.serve(conn)
+	}()
+
+	host, port, _ := net.SplitHostPort(ln.Addr().String())
+	config := &TCPConfig{}
+	config.Default()
+	config.Host = host
+	config.Port, _ = strconv.Atoi(port)
+
+	return printer, config
+}
+
+func TestTCPConfigDefault(t *testing.T) {
+	var c TCPConfig
+	c.Default()
+
+	if c.Port != 9100 {
+		t.Errorf("default port %d, want 9100", c.Port)
+	}
+
+	if c.DialTimeout <= 0 || c.ReadTimeout <= 0 || c.WriteTimeout <= 0 {
+		t.Errorf("default timeouts %s/%s/%s, want them set", c.DialTimeout, c.ReadTimeout, c.WriteTimeout)
+	}
+}
+
+func TestTCPConnectDefaultsToPort9100(t *testing.T) {
+	_, config := listenPrinter(t, "127.0.0.1:9100")
+	config.Port = 0
+
+	rwc, err := config.connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rwc.Close()
+}
+
+func TestTCPStatusRead(t *testing.T) {
+	printer, config := listenPrinter(t, "127.0.0.1:0")
+	printer.status[3] = 0x12 | commands.AUTOCUTER_STATUS_MASK
+
+	rwc, err := config.connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rwc.Close()
+
+	d := commands.NewDriver(rwc)
+
+	jammed, err := d.GetAutocutterStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !jammed {
+		t.Error("autocutter reported fine")
+	}
+}
+
+func TestTCPPrinter(t *testing.T) {
+	printer, config := listenPrinter(t, "127.0.0.1:0")
+
+	p, err := New(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := p.Println("hello"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The status comes back once the text before it has been received
+	if _, err := p.driver.GetAutocutterStatus(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []byte("hello\n\x1bd\x0a\x10\x04\x03")
+	if got := printer.bytes(); !bytes.Equal(got, want) {
+		t.Errorf("printer received % x, want % x", got, want)
+	}
+}
+
+func TestTCPReadTimeout(t *testing.T) {
+	_, config := listenPrinter(t, "127.0.0.1:0")
+	config.ReadTimeout = 50 * time.Millisecond
+
+	rwc, err := config.connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rwc.Close()
+
+	start := time.Now()
+	_, err = rwc.Read(make([]byte, 1))
+
+	var netErr net.Error
+	if !errors.As(err, &netErr) || !netErr.Timeout() {
+		t.Fatalf("got %v, want a timeout", err)
+	}
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("read timed out after %s, want about 50ms", elapsed)
+	}
+}
+
+func TestTCPExplicitDeadlineTakesPrecedence(t *testing.T) {
+	_, config := listenPrinter(t, "127.0.0.1:0")
+
+	rwc, err := config.connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rwc.Close()
+
+	rwc.(interface{ SetReadDeadline(time.Time) error }).SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+
+	start := time.Now()
+	_, err = rwc.Read(make([]byte, 1))
+
+	var netErr net.Error
+	if !errors.As(err, &netErr) || !netErr.Timeout() {
+		t.Fatalf("got %v, want a timeout", err)
+	}
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("read timed out after %s, want about 50ms", elapsed)
+	}
+}
+
+func TestTCPStatusTimeout(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip(err)
+	}
+	defer ln.Close()
+
+	// Accepts the connection and never answers
+	go func() {
+		conn, err := ln.Accept()
+		if err == nil {
+			io.Copy(io.Discard, conn)
+		}
+	}()
+
+	config := &TCPConfig{}
+	config.Default()
+	config.Host = "127.0.0.1"
+	config.Port = ln.Addr().(*net.TCPAddr).Port
+
+	rwc, err := config.connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rwc.Close()
+
+	d := commands.NewDriver(rwc)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	if _, err := d.WithContext(ctx).GetAutocutterStatus(); !errors.Is(err, commands.ErrTimeout) {
+		t.Errorf("got %v, want ErrTimeout", err)
+	}
+}
+
+func TestTCPDialError(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip(err)
+	}
+	addr := ln.Addr().(*net.TCPAddr)
+	ln.Close()
+
+	config := &TCPConfig{}
+	config.Default()
+	config.Host = "127.0.0.1"
+	config.Port = addr.Port
+	config.DialTimeout = time.Second
+
+	if _, err := config.connect(); err == nil {
+		t.Error("dialed a closed port")
+	}
+}
@@ -0,0 +1,141 @@
+package rongta
+
+import (
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cyb3rjerry/rongta-escpos/commands"
+	"go.bug.st/serial"
+)
+
+// Controls which ports and baud rates DiscoverSerial probes
+type DiscoverOptions struct {
+	Ports     []string // Empty probes every port reported by the OS
+	BaudRates []int    // Tried in order, the first one answering wins
+	Timeout   time.Duration
+}
+
+func (o *DiscoverOptions) Default() {
+	o.Ports = nil
+	o.BaudRates = []int{19200, 9600, 38400, 57600, 115200}
+	o.Timeout = 300 * time.Millisecond
+}
+
+// Port enumeration and opening, replaced in tests
+var (
+	listSerialPorts = serial.GetPortsList
+	openSerialPort  = openProbe
+)
+
+// Finds serial ports with a Rongta printer attached
+// Every port is probed at each baud rate with a DLE EOT 1 real-time status
+// request, ports replying with a valid status byte are returned as
+// ready-to-use configs. Unset options (or a nil opts) take the default
+// value.
+func DiscoverSerial(opts *DiscoverOptions) ([]*SerialConfig, error) {
+	defaults := DiscoverOptions{}
+	defaults.Default()
+
+	o := DiscoverOptions{}
+	if opts != nil {
+		o = *opts
+	}
+
+	if len(o.BaudRates) == 0 {
+		o.BaudRates = defaults.BaudRates
+	}
+
+	if o.Timeout <= 0 {
+		o.Timeout = defaults.Timeout
+	}
+
+	ports := o.Ports
+	if len(ports) == 0 {
+		var err error
+		ports, err = listSerialPorts()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		found []*SerialConfig
+	)
+
+	for _, port := range ports {
+		wg.Add(1)
+		go func(port string) {
+			defer wg.Done()
+
+			for _, baud := range o.BaudRates {
+				c := &SerialConfig{}
+				c.Default()
+				c.Port = port
+				c.BaudRate = baud
+
+				if probeSerial(c, o.Timeout) {
+					mu.Lock()
+					found = append(found, c)
+					mu.Unlock()
+					return
+				}
+			}
+		}(port)
+	}
+
+	wg.Wait()
+
+	sort.Slice(found, func(i, j int) bool {
+		return found[i].Port < found[j].Port
+	})
+
+	return found, nil
+}
+
+// Sends DLE EOT 1 and checks the fixed bits of the reply
+func probeSerial(c *SerialConfig, timeout time.Duration) bool {
+	s, err := openSerialPort(c, timeout)
+	if err != nil {
+		return false
+	}
+	defer s.Close()
+
+	if _, err := s.Write([]byte{commands.DLE, commands.EOT, 0x01}); err != nil {
+		return false
+	}
+
+	buf := make([]byte, 1)
+	n, err := s.Read(buf)
+	if err != nil || n != 1 {
+		return false
+	}
+
+	return commands.IsRealTimeStatus(buf[0])
+}
+
+// Opens the port with reads timing out after timeout
+func openProbe(c *SerialConfig, timeout time.Duration) (io.ReadWriteCloser, error) {
+	s, err := openSerial(c, &serial.Mode{
+		BaudRate: c.BaudRate,
+		Parity:   c.Parity,
+		DataBits: c.DataBits,
+		StopBits: c.StopBits,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.SetReadTimeout(timeout); err != nil {
+		s.Close()
+		return nil, err
+	}
+
+	// Drop anything left over from a previous baud rate
+	s.ResetInputBuffer()
+
+	return s, nil
+}
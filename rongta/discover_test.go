@@ -0,0 +1,190 @@
+package rongta
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cyb3rjerry/rongta-escpos/commands"
+)
+
+// Serial port answering DLE EOT 1 with reply
+// A nil reply never answers, like a port without a printer or at the
+// wrong baud rate.
+type fakeSerialPort struct {
+	reply   []byte
+	pending []byte
+}
+
+func (s *fakeSerialPort) Write(b []byte) (int, error) {
+	if s.reply != nil && string(b) == string([]byte{commands.DLE, commands.EOT, 0x01}) {
+		s.pending = append(s.pending, s.reply...)
+	}
+
+	return len(b), nil
+}
+
+// Like a serial port, an expired read timeout reads nothing
+func (s *fakeSerialPort) Read(b []byte) (int, error) {
+	n := copy(b, s.pending)
+	s.pending = s.pending[n:]
+
+	return n, nil
+}
+
+func (s *fakeSerialPort) Close() error {
+	return nil
+}
+
+// Serial ports with printers answering at a single baud rate
+type fakeSerialPorts struct {
+	mu       sync.Mutex
+	printers map[string]int    // Baud rate of the printer on each port
+	replies  map[string][]byte // Status sent by the printer, 0x12 by default
+	probed   []string          // Port and baud rate of every probe
+	timeout  time.Duration
+}
+
+// Replaces the OS ports with f until the test ends
+func (f *fakeSerialPorts) install(t *testing.T, ports ...string) {
+	list, open := listSerialPorts, openSerialPort
+	t.Cleanup(func() {
+		listSerialPorts, openSerialPort = list, open
+	})
+
+	listSerialPorts = func() ([]string, error) {
+		return ports, nil
+	}
+	openSerialPort = f.open
+}
+
+func (f *fakeSerialPorts) open(c *SerialConfig, timeout time.Duration) (io.ReadWriteCloser, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.probed = append(f.probed, fmt.Sprintf("%s@%d", c.Port, c.BaudRate))
+	f.timeout = timeout
+
+	baud, ok := f.printers[c.Port]
+	switch {
+	case !ok:
+		return nil, os.ErrNotExist
+	case baud != c.BaudRate:
+		return &fakeSerialPort{}, nil
+	}
+
+	reply, ok := f.replies[c.Port]
+	if !ok {
+		reply = []byte{0x12}
+	}
+
+	return &fakeSerialPort{reply: reply}, nil
+}
+
+func (f *fakeSerialPorts) probes() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	probes := append([]string(nil), f.probed...)
+	sort.Strings(probes)
+
+	return probes
+}
+
+func TestDiscoverSerial(t *testing.T) {
+	ports := &fakeSerialPorts{
+		printers: map[string]int{"/dev/ttyUSB0": 9600, "/dev/ttyUSB1": 115200, "/dev/ttyS0": 19200},
+		// Not a real-time status, something else is on this port
+		replies: map[string][]byte{"/dev/ttyS0": {0xff}},
+	}
+	ports.install(t, "/dev/ttyUSB1", "/dev/ttyS0", "/dev/ttyS1", "/dev/ttyUSB0")
+
+	found, err := DiscoverSerial(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, c := range found {
+		got = append(got, fmt.Sprintf("%s@%d", c.Port, c.BaudRate))
+
+		if c.DataBits != 8 {
+			t.Errorf("%s: %d data bits, want the SerialConfig default", c.Port, c.DataBits)
+		}
+	}
+
+	if want := []string{"/dev/ttyUSB0@9600", "/dev/ttyUSB1@115200"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("found %v, want %v", got, want)
+	}
+}
+
+func TestDiscoverSerialStopsAtFirstBaudRate(t *testing.T) {
+	ports := &fakeSerialPorts{printers: map[string]int{"/dev/ttyUSB0": 9600}}
+	ports.install(t)
+
+	_, err := DiscoverSerial(&DiscoverOptions{
+		Ports:     []string{"/dev/ttyUSB0"},
+		BaudRates: []int{19200, 9600, 38400},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"/dev/ttyUSB0@19200", "/dev/ttyUSB0@9600"}
+	if got := ports.probes(); !reflect.DeepEqual(got, want) {
+		t.Errorf("probed %v, want %v", got, want)
+	}
+}
+
+func TestDiscoverSerialFillsUnsetOptions(t *testing.T) {
+	var defaults DiscoverOptions
+	defaults.Default()
+
+	tests := []struct {
+		name    string
+		opts    DiscoverOptions
+		probes  int
+		timeout time.Duration
+	}{
+		{"empty", DiscoverOptions{}, 2 * len(defaults.BaudRates), defaults.Timeout},
+		{"ports only", DiscoverOptions{Ports: []string{"/dev/ttyS0"}}, len(defaults.BaudRates), defaults.Timeout},
+		{"timeout only", DiscoverOptions{Timeout: time.Second}, 2 * len(defaults.BaudRates), time.Second},
+		{"baud rates only", DiscoverOptions{BaudRates: []int{9600}}, 2, defaults.Timeout},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Nothing answers, every port is probed at every baud rate
+			ports := &fakeSerialPorts{printers: map[string]int{"/dev/ttyS0": 0, "/dev/ttyS1": 0}}
+			ports.install(t, "/dev/ttyS0", "/dev/ttyS1")
+
+			opts := test.opts
+			found, err := DiscoverSerial(&opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(found) != 0 {
+				t.Errorf("found %d printers, want none", len(found))
+			}
+
+			if n := len(ports.probes()); n != test.probes {
+				t.Errorf("%d probes, want %d", n, test.probes)
+			}
+
+			if ports.timeout != test.timeout {
+				t.Errorf("probed with a %s timeout, want %s", ports.timeout, test.timeout)
+			}
+
+			// The caller's options are left alone
+			if !reflect.DeepEqual(opts, test.opts) {
+				t.Errorf("options changed to %+v", opts)
+			}
+		})
+	}
+}
@@ -11,6 +11,7 @@ func main() {
 	if err != nil {
 		panic(err)
 	}
+	defer printer.Close()
 
 	err = printer.Init()
 	if err != nil {
@@ -0,0 +1,428 @@
+package rongta
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cyb3rjerry/rongta-escpos/commands"
+)
+
+var (
+	ErrReconnectFailed = errors.New("failed to reconnect to the printer")
+
+	// Same error as the driver's, a closed printer fails alike whether the
+	// call ends in the printer or in its driver
+	ErrClosed = commands.ErrClosed
+)
+
+// Reported for every reconnection attempt
+type ReconnectEvent struct {
+	Attempt int
+	Cause   error // I/O error that triggered the reconnection
+	Err     error // nil when the attempt succeeded
+}
+
+// Controls how a dead connection is reestablished
+type ReconnectPolicy struct {
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	MaxAttempts    int // Zero retries forever
+	OnEvent        func(ReconnectEvent)
+}
+
+func (r *ReconnectPolicy) Default() {
+	r.InitialBackoff = 500 * time.Millisecond
+	r.MaxBackoff = 30 * time.Second
+	r.MaxAttempts = 10
+}
+
+// reconnectingConn reopens the connection through the original config when
+// a read or write fails. Failed writes are retried once on the new
+// connection, failed reads go on reading from it.
+type reconnectingConn struct {
+	connect func() (io.ReadWriteCloser, error)
+	policy  ReconnectPolicy
+	setup   func(*commands.Driver) error
+	opts    []commands.Option // Settings of the setup driver
+
+	mu           sync.Mutex
+	rwc          io.ReadWriteCloser
+	dead         bool          // rwc failed and was closed, no new connection yet
+	reconnecting chan struct{} // Closed when the reconnection in progress ends
+	err          error         // Why the last reconnection failed
+	closed       bool
+	done         chan struct{}
+	closeOnce    sync.Once
+
+	// Deadlines of the connection's user, they also limit the wait for a
+	// reconnection
+	readDeadline  time.Time
+	writeDeadline time.Time
+	deadlineSet   chan struct{} // Closed when a deadline changes
+
+	// Reads of the setup driver and of the connection's user take turns,
+	// see setupConn
+	readMu  sync.Mutex
+	pending []byte
+}
+
+func newReconnectingConn(connect func() (io.ReadWriteCloser, error), rwc io.ReadWriteCloser, policy ReconnectPolicy, setup func(*commands.Driver) error, opts ...commands.Option) *reconnectingConn {
+	// Don't spin on a zero backoff
+	if policy.InitialBackoff <= 0 {
+		policy.InitialBackoff = 500 * time.Millisecond
+	}
+
+	return &reconnectingConn{
+		connect:     connect,
+		policy:      policy,
+		setup:       setup,
+		opts:        opts,
+		rwc:         rwc,
+		done:        make(chan struct{}),
+		deadlineSet: make(chan struct{}),
+	}
+}
+
+func (c *reconnectingConn) current() (io.ReadWriteCloser, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return nil, ErrClosed
+	}
+
+	return c.rwc, nil
+}
+
+func (c *reconnectingConn) Write(b []byte) (int, error) {
+	rwc, err := c.current()
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := rwc.Write(b)
+	if err == nil {
+		return n, nil
+	}
+
+	rwc, err = c.reconnect(rwc, err, &c.writeDeadline)
+	if err != nil {
+		return n, err
+	}
+
+	return rwc.Write(b)
+}
+
+func (c *reconnectingConn) Read(b []byte) (int, error) {
+	rwc, n, err := c.read(b)
+	if rwc == nil || err == nil || isTimeout(err) {
+		return n, err
+	}
+
+	_, rerr := c.reconnect(rwc, err, &c.readDeadline)
+	if rerr != nil {
+		return n, rerr
+	}
+
+	// The reader carries on with the new connection
+	if n > 0 {
+		return n, nil
+	}
+
+	_, n, err = c.read(b)
+	return n, err
+}
+
+// Reads from the current connection, which is returned unless the bytes
+// come from the setup or the connection is closed
+func (c *reconnectingConn) read(b []byte) (io.ReadWriteCloser, int, error) {
+	c.readMu.Lock()
+	defer c.readMu.Unlock()
+
+	if len(c.pending) > 0 {
+		n := copy(b, c.pending)
+		c.pending = c.pending[n:]
+		return nil, n, nil
+	}
+
+	rwc, err := c.current()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	n, err := rwc.Read(b)
+	return rwc, n, err
+}
+
+func (c *reconnectingConn) Close() error {
+	// Stops a reconnection in progress
+	c.closeOnce.Do(func() { close(c.done) })
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return nil
+	}
+
+	c.closed = true
+
+	if c.dead {
+		return nil
+	}
+
+	return c.rwc.Close()
+}
+
+// Forwarded to the current connection so Driver contexts can interrupt
+// pending reads
+func (c *reconnectingConn) SetReadDeadline(t time.Time) error {
+	return c.setDeadline(&c.readDeadline, t, func(rwc io.ReadWriteCloser) error {
+		return setReadDeadline(rwc, t)
+	})
+}
+
+func (c *reconnectingConn) SetWriteDeadline(t time.Time) error {
+	return c.setDeadline(&c.writeDeadline, t, func(rwc io.ReadWriteCloser) error {
+		return setWriteDeadline(rwc, t)
+	})
+}
+
+// Forwards a deadline to the current connection and records it for the
+// wait on a reconnection. A connection without deadlines clears it: the
+// driver then stops waiting on its own, and a reconnection must not fail
+// the operation with a deadline error first.
+func (c *reconnectingConn) setDeadline(deadline *time.Time, t time.Time, forward func(io.ReadWriteCloser) error) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return ErrClosed
+	}
+
+	var err error
+	if !c.dead {
+		err = forward(c.rwc)
+	}
+
+	if err != nil {
+		t = time.Time{}
+	}
+
+	*deadline = t
+	close(c.deadlineSet)
+	c.deadlineSet = make(chan struct{})
+
+	return err
+}
+
+// Replaces the failed connection, unless another caller already did
+// The new connection is set up in the background without holding the
+// lock, so Close and the deadline setters aren't held up meanwhile. Every
+// caller waits for the same reconnection, until deadline at most.
+func (c *reconnectingConn) reconnect(failed io.ReadWriteCloser, cause error, deadline *time.Time) (io.ReadWriteCloser, error) {
+	c.mu.Lock()
+
+	if c.closed {
+		c.mu.Unlock()
+		return nil, ErrClosed
+	}
+
+	if c.rwc != failed {
+		rwc := c.rwc
+		c.mu.Unlock()
+		return rwc, nil
+	}
+
+	wait := c.reconnecting
+	if wait == nil {
+		if !c.dead {
+			failed.Close()
+			c.dead = true
+		}
+
+		wait = make(chan struct{})
+		c.reconnecting = wait
+		go c.redial(cause, wait)
+	}
+	c.mu.Unlock()
+
+	if err := c.await(wait, deadline); err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	switch {
+	case c.closed:
+		return nil, ErrClosed
+	case c.dead:
+		return nil, c.err
+	}
+
+	return c.rwc, nil
+}
+
+// Waits for the reconnection to end, the connection to be closed or the
+// deadline to expire. The deadline may change meanwhile.
+func (c *reconnectingConn) await(wait chan struct{}, deadline *time.Time) error {
+	for {
+		c.mu.Lock()
+		t, set := *deadline, c.deadlineSet
+		c.mu.Unlock()
+
+		var expired <-chan time.Time
+		if !t.IsZero() {
+			expired = time.After(time.Until(t))
+		}
+
+		select {
+		case <-wait:
+			return nil
+		case <-c.done:
+			return ErrClosed
+		case <-set:
+		case <-expired:
+			return os.ErrDeadlineExceeded
+		}
+	}
+}
+
+// Installs a new connection, or records why there is none, then wakes up
+// the callers waiting on wait
+func (c *reconnectingConn) redial(cause error, wait chan struct{}) {
+	rwc, err := c.dial(cause)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.reconnecting = nil
+	close(wait)
+
+	switch {
+	case c.closed:
+		if rwc != nil {
+			rwc.Close()
+		}
+	case err != nil:
+		c.err = err
+	default:
+		c.rwc, c.dead, c.err = rwc, false, nil
+	}
+}
+
+// Connects until an attempt succeeds, the policy gives up or the
+// connection is closed
+func (c *reconnectingConn) dial(cause error) (io.ReadWriteCloser, error) {
+	backoff := c.policy.InitialBackoff
+	var err error
+
+	for attempt := 1; c.policy.MaxAttempts == 0 || attempt <= c.policy.MaxAttempts; attempt++ {
+		var rwc io.ReadWriteCloser
+
+		rwc, err = c.connect()
+		if err == nil {
+			err = c.initialize(rwc)
+			if err != nil {
+				rwc.Close()
+			}
+		}
+
+		if c.policy.OnEvent != nil {
+			c.policy.OnEvent(ReconnectEvent{Attempt: attempt, Cause: cause, Err: err})
+		}
+
+		if err == nil {
+			return rwc, nil
+		}
+
+		select {
+		case <-time.After(backoff):
+		case <-c.done:
+			return nil, ErrClosed
+		}
+
+		backoff *= 2
+		if c.policy.MaxBackoff > 0 && backoff > c.policy.MaxBackoff {
+			backoff = c.policy.MaxBackoff
+		}
+	}
+
+	return nil, fmt.Errorf("%w: %w", ErrReconnectFailed, err)
+}
+
+// Brings a fresh connection back to the state the session expects
+// The driver used for it has the settings of the printer's driver, and is
+// closed before the connection is handed over.
+func (c *reconnectingConn) initialize(rwc io.ReadWriteCloser) error {
+	conn := &setupConn{c: c, rwc: rwc}
+	driver := commands.NewDriver(conn, c.opts...)
+
+	defer func() {
+		conn.over.Store(true)
+		driver.Close()
+	}()
+
+	if err := driver.Initialize(); err != nil {
+		return err
+	}
+
+	if c.setup != nil {
+		if err := c.setup(driver); err != nil {
+			return err
+		}
+	}
+
+	// Buffered drivers keep the setup until flushed
+	return driver.Flush()
+}
+
+// Connection of the driver setting up a new connection
+// A setup sending queries starts a reader of its own. Its reads take turns
+// with the reads of the connection's user, and what it still reads once
+// the setup is over is handed back to them, so that it doesn't steal
+// replies or ASB frames.
+type setupConn struct {
+	c    *reconnectingConn
+	rwc  io.ReadWriteCloser
+	over atomic.Bool
+}
+
+func (s *setupConn) Read(b []byte) (int, error) {
+	s.c.readMu.Lock()
+	defer s.c.readMu.Unlock()
+
+	if s.over.Load() {
+		return 0, io.EOF
+	}
+
+	n, err := s.rwc.Read(b)
+	if s.over.Load() {
+		s.c.pending = append(s.c.pending, b[:n]...)
+		return 0, io.EOF
+	}
+
+	return n, err
+}
+
+func (s *setupConn) Write(b []byte) (int, error) {
+	return s.rwc.Write(b)
+}
+
+// The connection outlives the setup
+func (s *setupConn) Close() error {
+	return nil
+}
+
+// Read timeouts are expected while waiting for replies and don't mean the
+// connection is dead
+func isTimeout(err error) bool {
+	var timeout interface{ Timeout() bool }
+	return errors.As(err, &timeout) && timeout.Timeout()
+}
@@ -0,0 +1,527 @@
+package rongta
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cyb3rjerry/rongta-escpos/commands"
+)
+
+var errUnplugged = errors.New("device unplugged")
+
+// Fake printer connection recording the bytes written
+// Reads block until bytes are injected, a scripted reply is sent or the
+// connection is closed.
+type fakeConn struct {
+	mu      sync.Mutex
+	written []byte
+	pending []byte // Written bytes not matched against a reply yet
+	replies []fakeReply
+	unread  []byte
+	wake    chan struct{}
+	closed  bool
+}
+
+// Reply sent back once request is written
+type fakeReply struct {
+	request []byte
+	reply   []byte
+}
+
+func newFakeConn() *fakeConn {
+	return &fakeConn{wake: make(chan struct{})}
+}
+
+func (c *fakeConn) Write(b []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return 0, os.ErrClosed
+	}
+
+	c.written = append(c.written, b...)
+	c.pending = append(c.pending, b...)
+
+	for len(c.replies) > 0 {
+		next := c.replies[0]
+
+		i := bytes.Index(c.pending, next.request)
+		if i < 0 {
+			break
+		}
+
+		c.pending = c.pending[i+len(next.request):]
+		c.replies = c.replies[1:]
+		c.unread = append(c.unread, next.reply...)
+		c.notify()
+	}
+
+	return len(b), nil
+}
+
+func (c *fakeConn) Read(b []byte) (int, error) {
+	for {
+		c.mu.Lock()
+
+		if len(c.unread) > 0 {
+			n := copy(b, c.unread)
+			c.unread = c.unread[n:]
+			c.mu.Unlock()
+			return n, nil
+		}
+
+		if c.closed {
+			c.mu.Unlock()
+			return 0, io.EOF
+		}
+
+		wake := c.wake
+		c.mu.Unlock()
+
+		<-wake
+	}
+}
+
+func (c *fakeConn) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return os.ErrClosed
+	}
+
+	c.closed = true
+	c.notify()
+
+	return nil
+}
+
+// Queues a reply sent once request is written, after the replies queued
+// before it
+func (c *fakeConn) reply(request []byte, reply ...byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.replies = append(c.replies, fakeReply{request, reply})
+}
+
+// Sends b to the host
+func (c *fakeConn) inject(b ...byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.unread = append(c.unread, b...)
+	c.notify()
+}
+
+// Returns a copy of every byte written
+func (c *fakeConn) bytes() []byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return bytes.Clone(c.written)
+}
+
+// Wakes up blocked readers, must be called with the lock held
+func (c *fakeConn) notify() {
+	close(c.wake)
+	c.wake = make(chan struct{})
+}
+
+// Connector failing on a schedule, each nil entry (or the end of the
+// schedule) hands out a new fake connection, scripted by prepare if set
+type fakeConnector struct {
+	mu       sync.Mutex
+	schedule []error
+	prepare  func(conn *fakeConn)
+	calls    []time.Time
+	conns    []*fakeConn
+}
+
+func (f *fakeConnector) connect() (io.ReadWriteCloser, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	n := len(f.calls)
+	f.calls = append(f.calls, time.Now())
+
+	if n < len(f.schedule) && f.schedule[n] != nil {
+		return nil, f.schedule[n]
+	}
+
+	conn := newFakeConn()
+	if f.prepare != nil {
+		f.prepare(conn)
+	}
+	f.conns = append(f.conns, conn)
+
+	return conn, nil
+}
+
+func (f *fakeConnector) attempts() []time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]time.Time(nil), f.calls...)
+}
+
+func (f *fakeConnector) last() *fakeConn {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.conns[len(f.conns)-1]
+}
+
+// Collects the reconnect events
+type eventLog struct {
+	mu     sync.Mutex
+	events []ReconnectEvent
+}
+
+func (l *eventLog) record(e ReconnectEvent) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.events = append(l.events, e)
+}
+
+func (l *eventLog) all() []ReconnectEvent {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return append([]ReconnectEvent(nil), l.events...)
+}
+
+// A connection that is already dead
+func deadConn() *fakeConn {
+	conn := newFakeConn()
+	conn.Close()
+
+	return conn
+}
+
+func TestReconnectRetriesWithBackoff(t *testing.T) {
+	connector := &fakeConnector{schedule: []error{errUnplugged, errUnplugged, nil}}
+	var log eventLog
+
+	setup := func(d *commands.Driver) error {
+		return d.SetJustification(commands.JustifyCenter)
+	}
+
+	rc := newReconnectingConn(connector.connect, deadConn(), ReconnectPolicy{
+		InitialBackoff: 20 * time.Millisecond,
+		MaxBackoff:     30 * time.Millisecond,
+		OnEvent:        log.record,
+	}, setup)
+	defer rc.Close()
+
+	if _, err := rc.Write([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+
+	calls := connector.attempts()
+	if len(calls) != 3 {
+		t.Fatalf("%d connection attempts, want 3", len(calls))
+	}
+
+	// 20ms, then doubled and capped at 30ms
+	for i, want := range []time.Duration{20 * time.Millisecond, 30 * time.Millisecond} {
+		if got := calls[i+1].Sub(calls[i]); got < want {
+			t.Errorf("attempt %d came %s after the previous one, want at least %s", i+2, got, want)
+		}
+	}
+
+	events := log.all()
+	if len(events) != 3 {
+		t.Fatalf("%d events, want 3", len(events))
+	}
+
+	for i, e := range events {
+		if e.Attempt != i+1 {
+			t.Errorf("event %d reports attempt %d", i, e.Attempt)
+		}
+
+		if e.Cause == nil {
+			t.Errorf("event %d has no cause", i)
+		}
+
+		if failed := e.Err != nil; failed != (i < 2) {
+			t.Errorf("event %d has error %v", i, e.Err)
+		}
+	}
+
+	// The new connection is initialized and set up before the write is
+	// retried on it
+	want := []byte("\x1b@\x1ba\x01hello")
+	if got := connector.last().bytes(); !bytes.Equal(got, want) {
+		t.Errorf("wrote % x, want % x", got, want)
+	}
+}
+
+func TestReconnectRetriesFailedSetup(t *testing.T) {
+	connector := &fakeConnector{}
+	var log eventLog
+
+	calls := 0
+	setup := func(d *commands.Driver) error {
+		calls++
+		if calls == 1 {
+			return errUnplugged
+		}
+
+		return nil
+	}
+
+	rc := newReconnectingConn(connector.connect, deadConn(), ReconnectPolicy{
+		InitialBackoff: time.Millisecond,
+		OnEvent:        log.record,
+	}, setup)
+	defer rc.Close()
+
+	if _, err := rc.Write([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+
+	events := log.all()
+	if len(events) != 2 || !errors.Is(events[0].Err, errUnplugged) || events[1].Err != nil {
+		t.Errorf("got events %+v, want a failed setup then a success", events)
+	}
+}
+
+func TestReconnectGivesUpAfterMaxAttempts(t *testing.T) {
+	connector := &fakeConnector{schedule: []error{errUnplugged, errUnplugged, errUnplugged, nil}}
+	var log eventLog
+
+	rc := newReconnectingConn(connector.connect, deadConn(), ReconnectPolicy{
+		InitialBackoff: time.Millisecond,
+		MaxAttempts:    3,
+		OnEvent:        log.record,
+	}, nil)
+	defer rc.Close()
+
+	_, err := rc.Write([]byte("hello"))
+	if !errors.Is(err, ErrReconnectFailed) || !errors.Is(err, errUnplugged) {
+		t.Errorf("got %v, want ErrReconnectFailed wrapping the last error", err)
+	}
+
+	if n := len(connector.attempts()); n != 3 {
+		t.Errorf("%d connection attempts, want 3", n)
+	}
+
+	if n := len(log.all()); n != 3 {
+		t.Errorf("%d events, want 3", n)
+	}
+}
+
+func TestReconnectKeepsASBSubscribers(t *testing.T) {
+	first, second := newFakeConn(), newFakeConn()
+
+	rc := newReconnectingConn(func() (io.ReadWriteCloser, error) {
+		return second, nil
+	}, first, ReconnectPolicy{InitialBackoff: time.Millisecond}, nil)
+
+	d := commands.NewDriver(rc)
+	defer d.Close()
+
+	frames, cancel := d.SubscribeASB()
+	defer cancel()
+
+	// The printer goes away, the reader reconnects and reads on
+	first.Close()
+
+	frame := commands.ASBFrame{0x10 | 0x08, 0x00, 0x00, 0x00}
+	second.inject(frame[:]...)
+
+	select {
+	case got := <-frames:
+		if got != frame {
+			t.Errorf("got frame % x, want % x", got, frame)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("frame from the new connection not delivered")
+	}
+}
+
+func TestReconnectSetupQuery(t *testing.T) {
+	jammed := commands.REALTIME_STATUS_FIXED_BITS | commands.AUTOCUTER_STATUS_MASK
+
+	connector := &fakeConnector{prepare: func(conn *fakeConn) {
+		conn.reply([]byte{commands.GS, 'r', 1}, 0x00)
+		conn.reply([]byte{commands.DLE, commands.EOT, 3}, jammed)
+	}}
+
+	setup := func(d *commands.Driver) error {
+		_, err := d.TransmitStatus()
+		return err
+	}
+
+	rc := newReconnectingConn(connector.connect, deadConn(), ReconnectPolicy{InitialBackoff: time.Millisecond}, setup)
+
+	d := commands.NewDriver(rc)
+	defer d.Close()
+
+	frames, cancel := d.SubscribeASB()
+	defer cancel()
+
+	// The setup's reader must not take the reply, nor the frame after it
+	ctx, stop := context.WithTimeout(context.Background(), time.Second)
+	defer stop()
+
+	failed, err := d.WithContext(ctx).GetAutocutterStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !failed {
+		t.Error("cutter reported working")
+	}
+
+	frame := commands.ASBFrame{0x10 | 0x08, 0x00, 0x00, 0x00}
+	connector.last().inject(frame[:]...)
+
+	select {
+	case got := <-frames:
+		if got != frame {
+			t.Errorf("got frame % x, want % x", got, frame)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("frame read by the setup driver")
+	}
+
+	want := []byte("\x1b@\x1dr\x01\x10\x04\x03")
+	if got := connector.last().bytes(); !bytes.Equal(got, want) {
+		t.Errorf("wrote % x, want % x", got, want)
+	}
+}
+
+func TestCloseDuringReconnect(t *testing.T) {
+	connector := &fakeConnector{schedule: []error{errUnplugged, errUnplugged, errUnplugged}}
+
+	attempted := make(chan struct{}, 1)
+	rc := newReconnectingConn(connector.connect, deadConn(), ReconnectPolicy{
+		InitialBackoff: time.Hour,
+		OnEvent: func(ReconnectEvent) {
+			select {
+			case attempted <- struct{}{}:
+			default:
+			}
+		},
+	}, nil)
+
+	result := make(chan error, 1)
+	go func() {
+		_, err := rc.Write([]byte("hello"))
+		result <- err
+	}()
+
+	<-attempted
+	if err := rc.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case err := <-result:
+		if !errors.Is(err, ErrClosed) {
+			t.Errorf("got %v, want ErrClosed", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Close didn't stop the reconnection")
+	}
+
+	if _, err := rc.Write([]byte("hello")); !errors.Is(err, ErrClosed) {
+		t.Errorf("write after Close returned %v, want ErrClosed", err)
+	}
+}
+
+func TestContextEndsWaitForReconnection(t *testing.T) {
+	connector := &fakeConnector{schedule: []error{errUnplugged, errUnplugged}}
+
+	attempted := make(chan struct{}, 1)
+	rc := newReconnectingConn(connector.connect, deadConn(), ReconnectPolicy{
+		InitialBackoff: time.Hour,
+		OnEvent: func(ReconnectEvent) {
+			select {
+			case attempted <- struct{}{}:
+			default:
+			}
+		},
+	}, nil)
+	defer rc.Close()
+
+	d := commands.NewDriver(rc)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if err := d.WithContext(ctx).SetEmphasizedMode(1); !errors.Is(err, commands.ErrTimeout) {
+		t.Errorf("got %v, want ErrTimeout", err)
+	}
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("deadline hit after %s", elapsed)
+	}
+
+	// The reconnection goes on in the background, a cancellation ends the
+	// next wait as well
+	<-attempted
+
+	ctx, cancel = context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+
+	if err := d.WithContext(ctx).SetEmphasizedMode(1); !errors.Is(err, context.Canceled) {
+		t.Errorf("got %v, want context.Canceled", err)
+	}
+
+	if n := len(connector.attempts()); n != 1 {
+		t.Errorf("%d connection attempts, want the one in progress", n)
+	}
+}
+
+// Config handing out the connector's connections
+type fakeConfig struct {
+	*fakeConnector
+}
+
+func (fakeConfig) Default()       {}
+func (fakeConfig) String() string { return "fake://" }
+
+func TestPrinterReconnects(t *testing.T) {
+	connector := &fakeConnector{}
+	var log eventLog
+
+	p, err := New(fakeConfig{connector}, WithReconnect(ReconnectPolicy{
+		InitialBackoff: time.Millisecond,
+		OnEvent:        log.record,
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The printer goes away
+	connector.last().Close()
+
+	if err := p.Println("after"); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := len(connector.attempts()); n != 2 {
+		t.Errorf("%d connections, want the first and a reconnection", n)
+	}
+
+	if events := log.all(); len(events) != 1 || events[0].Err != nil {
+		t.Errorf("got events %+v, want one successful reconnection", events)
+	}
+
+	want := []byte("\x1b@after\n\x1bd\x0a")
+	if got := connector.last().bytes(); !bytes.Equal(got, want) {
+		t.Errorf("wrote % x, want % x", got, want)
+	}
+}
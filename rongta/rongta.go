@@ -1,33 +1,177 @@
 package rongta
 
 import (
+	"context"
+	"io"
+	"sync"
+	"time"
+
 	"github.com/cyb3rjerry/rongta-escpos/commands"
 )
 
-var ()
-
 type Printer struct {
 	driver *commands.Driver
+
+	reconnect *ReconnectPolicy
+	setup     func(*commands.Driver) error
+	capture   io.Writer
+	profile   *commands.Profile
+	options   []commands.Option
+
+	mu     sync.Mutex
+	closed bool
+}
+
+// Optional printer settings, passed to New
+type Option func(*Printer)
+
+// Reconnect through the config when the connection fails
+// Initialize and the setup function are re-run on every new connection
+func WithReconnect(policy ReconnectPolicy) Option {
+	return func(p *Printer) {
+		p.reconnect = &policy
+	}
+}
+
+// Session setup (code page, fonts, ...) applied by Init, Reset and after
+// every reconnection
+func WithSetup(setup func(*commands.Driver) error) Option {
+	return func(p *Printer) {
+		p.setup = setup
+	}
+}
+
+// Validate commands against the printer model, see commands.LookupProfile
+// Reset also limits the printing area to the profile's PrintableDots.
+func WithProfile(profile *commands.Profile) Option {
+	return func(p *Printer) {
+		p.profile = profile
+	}
+}
+
+// Extra driver settings, like commands.WithPacing or
+// commands.WithInterceptors
+func WithDriverOptions(opts ...commands.Option) Option {
+	return func(p *Printer) {
+		p.options = append(p.options, opts...)
+	}
 }
 
 // Requires a config struct to initialize the printer
 // Default values can be initiated by calling the config.Default() method
-func New(config Config) (*Printer, error) {
+func New(config Config, opts ...Option) (*Printer, error) {
 
 	p := &Printer{}
+	for _, opt := range opts {
+		opt(p)
+	}
 
-	rwc, err := config.connect()
+	connect := config.connect
+	if p.capture != nil {
+		connect = captureConnections(connect, p.capture)
+	}
+
+	rwc, err := connect()
 	if err != nil {
 		return nil, err
 	}
 
-	p.driver = commands.NewDriver(rwc)
+	driverOpts := append([]commands.Option{commands.WithProfile(p.profile)}, p.options...)
+
+	if p.reconnect != nil {
+		rwc = newReconnectingConn(connect, rwc, *p.reconnect, p.session, driverOpts...)
+	}
+
+	p.driver = commands.NewDriver(rwc, driverOpts...)
 
 	return p, nil
 }
 
 func (p *Printer) Init() error {
+	return p.Reset()
+}
+
+// Sends ESC @ and reapplies the session setup
+// Clears the print buffer and restores the power-on settings. With a
+// profile, the printing area is then limited to its PrintableDots.
+func (p *Printer) Reset() error {
+	if err := p.check(); err != nil {
+		return err
+	}
+
+	err := p.driver.Initialize()
+	if err != nil {
+		return err
+	}
+
+	return p.session(p.driver)
+}
+
+// Settings applied after ESC @, on d or on a new connection
+func (p *Printer) session(d *commands.Driver) error {
+	if p.profile != nil {
+		err := d.SetPrintingAreaWidthTo(p.profile.PrintableDots)
+		if err != nil {
+			return err
+		}
+	}
+
+	if p.setup != nil {
+		return p.setup(d)
+	}
+
+	return nil
+}
+
+// Waits until the printer has processed everything sent so far
+// GS r is processed in order with the print data, so its reply only comes
+// back once the data before it has been handled. Returns a
+// *commands.TimeoutError when the printer doesn't answer in time.
+func (p *Printer) Wait(timeout time.Duration) error {
+	if err := p.check(); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	_, err := p.driver.WithContext(ctx).TransmitStatus()
+	return err
+}
+
+// Releases the connection, later calls return ErrClosed
+func (p *Printer) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return ErrClosed
+	}
+
+	p.closed = true
+	return p.driver.Close()
+}
+
+// Waits up to timeout for the printer to finish its buffer, then closes the
+// connection. The connection is closed even when the wait fails.
+func (p *Printer) CloseWait(timeout time.Duration) error {
+	waitErr := p.Wait(timeout)
+
+	// Closing unblocks a status read still pending after a timeout
+	if err := p.Close(); err != nil {
+		return err
+	}
+
+	return waitErr
+}
+
+func (p *Printer) check() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return ErrClosed
+	}
 
-	p.driver.Initialize()
 	return nil
 }
@@ -0,0 +1,143 @@
+package rongta
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/cyb3rjerry/rongta-escpos/commands"
+)
+
+func TestPrinterCallsAfterClose(t *testing.T) {
+	connector := &fakeConnector{}
+
+	p, err := New(fakeConfig{connector})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The connection is released
+	if _, err := connector.last().Write([]byte{0}); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("connection still open, write returned %v", err)
+	}
+
+	calls := map[string]func() error{
+		"Init":      p.Init,
+		"Reset":     p.Reset,
+		"Println":   func() error { return p.Println("hello") },
+		"Wait":      func() error { return p.Wait(time.Second) },
+		"Close":     p.Close,
+		"CloseWait": func() error { return p.CloseWait(time.Second) },
+	}
+
+	for name, call := range calls {
+		if err := call(); !errors.Is(err, ErrClosed) || !errors.Is(err, commands.ErrClosed) {
+			t.Errorf("%s after Close returned %v, want ErrClosed", name, err)
+		}
+	}
+}
+
+func TestPrinterCloseWait(t *testing.T) {
+	connector := &fakeConnector{}
+
+	p, err := New(fakeConfig{connector})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn := connector.last()
+	conn.reply([]byte{commands.GS, 'r', 1}, 0x00)
+
+	if err := p.Println("hello"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := p.CloseWait(time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := conn.Write([]byte{0}); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("connection still open, write returned %v", err)
+	}
+}
+
+func TestPrinterCloseWaitTimeout(t *testing.T) {
+	connector := &fakeConnector{}
+
+	p, err := New(fakeConfig{connector})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The printer never answers GS r
+	start := time.Now()
+	if err := p.CloseWait(50 * time.Millisecond); !errors.Is(err, commands.ErrTimeout) {
+		t.Errorf("got %v, want ErrTimeout", err)
+	}
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("CloseWait returned after %s", elapsed)
+	}
+
+	// Closed all the same
+	if _, err := connector.last().Write([]byte{0}); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("connection still open, write returned %v", err)
+	}
+
+	if err := p.Println("hello"); !errors.Is(err, ErrClosed) {
+		t.Errorf("Println after CloseWait returned %v, want ErrClosed", err)
+	}
+}
+
+func TestResetLimitsPrintingAreaToProfile(t *testing.T) {
+	profile, _ := commands.LookupProfile("RP325")
+	connector := &fakeConnector{}
+
+	var setup int
+	p, err := New(fakeConfig{connector}, WithProfile(profile), WithSetup(func(*commands.Driver) error {
+		setup++
+		return nil
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	if err := p.Reset(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 384 dots are 378 horizontal motion units
+	want := []byte{commands.ESC, '@', commands.GS, 'W', 378 % 256, 378 / 256}
+	if got := connector.last().bytes(); !bytes.Equal(got, want) {
+		t.Errorf("wrote % x, want % x", got, want)
+	}
+
+	if setup != 1 {
+		t.Errorf("setup ran %d times, want once", setup)
+	}
+}
+
+func TestResetWithoutProfile(t *testing.T) {
+	connector := &fakeConnector{}
+
+	p, err := New(fakeConfig{connector})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	if err := p.Reset(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := connector.last().bytes(); !bytes.Equal(got, []byte{commands.ESC, '@'}) {
+		t.Errorf("wrote % x, want ESC @", got)
+	}
+}
@@ -0,0 +1,59 @@
+package rongta
+
+import (
+	"go.bug.st/serial"
+	"golang.org/x/sys/unix"
+)
+
+// Opens the serial port and applies the flow control setting
+// go.bug.st/serial turns flow control off when it opens a port and then
+// takes exclusive access to it. Termios settings belong to the tty rather
+// than to a descriptor, so a second descriptor opened beforehand is used to
+// turn it back on.
+func openSerial(c *SerialConfig, mode *serial.Mode) (serial.Port, error) {
+	if c.FlowControl == NoFlowControl {
+		return serial.Open(c.Port, mode)
+	}
+
+	fd, err := unix.Open(c.Port, unix.O_RDWR|unix.O_NOCTTY|unix.O_NONBLOCK|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer unix.Close(fd)
+
+	s, err := serial.Open(c.Port, mode)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := setFlowControl(fd, c.FlowControl); err != nil {
+		s.Close()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func setFlowControl(fd int, flow FlowControl) error {
+	t, err := unix.IoctlGetTermios(fd, unix.TCGETS)
+	if err != nil {
+		return err
+	}
+
+	t.Cflag &^= unix.CRTSCTS
+	t.Iflag &^= unix.IXON | unix.IXOFF | unix.IXANY
+
+	switch flow {
+	case RTSCTSFlowControl:
+		t.Cflag |= unix.CRTSCTS
+	case XONXOFFFlowControl:
+		// XON and XOFF are consumed by the tty, they never reach the driver
+		t.Iflag |= unix.IXON | unix.IXOFF
+		t.Cc[unix.VSTART] = 0x11
+		t.Cc[unix.VSTOP] = 0x13
+	default:
+		return ErrInvalidFlowControl
+	}
+
+	return unix.IoctlSetTermios(fd, unix.TCSETS, t)
+}
@@ -0,0 +1,17 @@
+//go:build !linux
+
+package rongta
+
+import (
+	"go.bug.st/serial"
+)
+
+// go.bug.st/serial has no flow control setting, it is only applied on
+// Linux
+func openSerial(c *SerialConfig, mode *serial.Mode) (serial.Port, error) {
+	if c.FlowControl != NoFlowControl {
+		return nil, ErrFlowControlUnsupported
+	}
+
+	return serial.Open(c.Port, mode)
+}
@@ -1,6 +1,10 @@
 package rongta
 
 func (p *Printer) Println(text string) error {
+	if err := p.check(); err != nil {
+		return err
+	}
+
 	err := p.driver.WriteStringToBuffer(text + "\n")
 	if err != nil {
 		return err
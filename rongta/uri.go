@@ -0,0 +1,363 @@
+package rongta
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.bug.st/serial"
+)
+
+var (
+	ErrInvalidURI        = errors.New("invalid connection URI")
+	ErrUnsupportedScheme = errors.New("unsupported connection URI scheme")
+)
+
+var parityNames = map[serial.Parity]string{
+	serial.NoParity:    "none",
+	serial.OddParity:   "odd",
+	serial.EvenParity:  "even",
+	serial.MarkParity:  "mark",
+	serial.SpaceParity: "space",
+}
+
+var flowControlNames = map[FlowControl]string{
+	NoFlowControl:      "none",
+	RTSCTSFlowControl:  "rtscts",
+	XONXOFFFlowControl: "xonxoff",
+}
+
+var stopBitsNames = map[serial.StopBits]string{
+	serial.OneStopBit:           "1",
+	serial.OnePointFiveStopBits: "1.5",
+	serial.TwoStopBits:          "2",
+}
+
+// Open a printer from a connection URI
+// See ParseURI for the accepted forms
+func Open(uri string, opts ...Option) (*Printer, error) {
+	config, err := ParseURI(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	return New(config, opts...)
+}
+
+// Parse a connection URI into a Config. Unset values keep the Default() value.
+//
+// serial:///dev/ttyUSB0?baud=9600&parity=none&databits=8&stopbits=1&flow=rtscts
+// serial://COM1?baud=19200
+// tcp://192.168.123.100:9100?dial_timeout=5s&read_timeout=5s&write_timeout=10s&keepalive=30s
+// usb://0fe6:811e/SERIAL?read_timeout=5s&write_timeout=10s
+// usb:///dev/usb/lp0
+// usb://lp0 (device node in /dev/usb)
+func ParseURI(uri string) (Config, error) {
+	scheme, rest, ok := strings.Cut(uri, "://")
+	if !ok {
+		return nil, fmt.Errorf("%w: %q: missing scheme", ErrInvalidURI, uri)
+	}
+
+	switch strings.ToLower(scheme) {
+	case "serial":
+		return parseSerialURI(uri)
+	case "tcp":
+		return parseTCPURI(uri)
+	case "usb":
+		// Not handed to url.Parse, "vid:pid" isn't a valid host:port
+		return parseUSBURI(uri, rest)
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedScheme, scheme)
+	}
+}
+
+func parseSerialURI(uri string) (*SerialConfig, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidURI, err)
+	}
+
+	c := &SerialConfig{}
+	c.Default()
+
+	// serial:///dev/ttyUSB0 has the port in the path, serial://COM1 in the
+	// host. serial://dev/ttyUSB0 lacks the third slash.
+	switch {
+	case u.Host != "" && u.Path != "":
+		return nil, fmt.Errorf("%w: %q: port paths start with a slash, as in serial:///dev/ttyUSB0", ErrInvalidURI, uri)
+	case u.Host != "":
+		c.Port = u.Host
+	case u.Path != "":
+		c.Port = u.Path
+	default:
+		return nil, fmt.Errorf("%w: %q: missing port", ErrInvalidURI, uri)
+	}
+
+	for key, values := range u.Query() {
+		value := values[len(values)-1]
+
+		switch key {
+		case "baud":
+			c.BaudRate, err = strconv.Atoi(value)
+			if err != nil || c.BaudRate <= 0 {
+				return nil, fmt.Errorf("%w: %q: baud must be a positive integer", ErrInvalidURI, uri)
+			}
+		case "parity":
+			parity, ok := lookupName(parityNames, value)
+			if !ok {
+				return nil, fmt.Errorf("%w: %q: parity must be one of none, odd, even, mark, space", ErrInvalidURI, uri)
+			}
+			c.Parity = parity
+		case "databits":
+			c.DataBits, err = strconv.Atoi(value)
+			if err != nil || c.DataBits < 5 || c.DataBits > 8 {
+				return nil, fmt.Errorf("%w: %q: databits must be between 5 and 8", ErrInvalidURI, uri)
+			}
+		case "stopbits":
+			stopBits, ok := lookupName(stopBitsNames, value)
+			if !ok {
+				return nil, fmt.Errorf("%w: %q: stopbits must be one of 1, 1.5, 2", ErrInvalidURI, uri)
+			}
+			c.StopBits = stopBits
+		case "flow":
+			flow, ok := lookupName(flowControlNames, value)
+			if !ok {
+				return nil, fmt.Errorf("%w: %q: flow must be one of none, rtscts, xonxoff", ErrInvalidURI, uri)
+			}
+			c.FlowControl = flow
+		default:
+			return nil, fmt.Errorf("%w: %q: unknown parameter %q", ErrInvalidURI, uri, key)
+		}
+	}
+
+	return c, nil
+}
+
+func parseTCPURI(uri string) (*TCPConfig, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidURI, err)
+	}
+
+	if u.Path != "" && u.Path != "/" {
+		return nil, fmt.Errorf("%w: %q: unexpected path %q", ErrInvalidURI, uri, u.Path)
+	}
+
+	c := &TCPConfig{}
+	c.Default()
+
+	c.Host = u.Hostname()
+	if c.Host == "" {
+		return nil, fmt.Errorf("%w: %q: missing host", ErrInvalidURI, uri)
+	}
+
+	if port := u.Port(); port != "" {
+		c.Port, err = strconv.Atoi(port)
+		if err != nil || c.Port <= 0 || c.Port > 65535 {
+			return nil, fmt.Errorf("%w: %q: invalid port %q", ErrInvalidURI, uri, port)
+		}
+	}
+
+	for key, values := range u.Query() {
+		var target *time.Duration
+
+		switch key {
+		case "dial_timeout":
+			target = &c.DialTimeout
+		case "read_timeout":
+			target = &c.ReadTimeout
+		case "write_timeout":
+			target = &c.WriteTimeout
+		case "keepalive":
+			target = &c.KeepAlive
+		default:
+			return nil, fmt.Errorf("%w: %q: unknown parameter %q", ErrInvalidURI, uri, key)
+		}
+
+		*target, err = time.ParseDuration(values[len(values)-1])
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q: %s: %w", ErrInvalidURI, uri, key, err)
+		}
+	}
+
+	return c, nil
+}
+
+func parseUSBURI(uri, rest string) (*USBConfig, error) {
+	c := &USBConfig{}
+	c.Default()
+
+	rest, rawQuery, _ := strings.Cut(rest, "?")
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidURI, err)
+	}
+
+	if strings.HasPrefix(rest, "/") {
+		// usb:///dev/usb/lp0
+		c.Device = rest
+	} else if isUSBDeviceName(rest) {
+		// usb://lp0
+		c.Device = filepath.Join(c.DevRoot, rest)
+	} else if rest != "" {
+		// usb://vid:pid/serial
+		ids, serialNumber, _ := strings.Cut(rest, "/")
+		c.Serial, err = url.PathUnescape(serialNumber)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidURI, err)
+		}
+
+		vid, pid, ok := strings.Cut(ids, ":")
+		if !ok {
+			return nil, fmt.Errorf("%w: %q: expected vid:pid", ErrInvalidURI, uri)
+		}
+
+		c.VendorID, err = parseUSBID(vid)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q: invalid vendor ID %q", ErrInvalidURI, uri, vid)
+		}
+
+		c.ProductID, err = parseUSBID(pid)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q: invalid product ID %q", ErrInvalidURI, uri, pid)
+		}
+	}
+
+	for key, values := range query {
+		var target *time.Duration
+
+		switch key {
+		case "read_timeout":
+			target = &c.ReadTimeout
+		case "write_timeout":
+			target = &c.WriteTimeout
+		default:
+			return nil, fmt.Errorf("%w: %q: unknown parameter %q", ErrInvalidURI, uri, key)
+		}
+
+		*target, err = time.ParseDuration(values[len(values)-1])
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q: %s: %w", ErrInvalidURI, uri, key, err)
+		}
+	}
+
+	return c, nil
+}
+
+// A device node name like lp0, as opposed to a vid:pid pair or a lone ID
+func isUSBDeviceName(s string) bool {
+	if s == "" || strings.ContainsAny(s, ":/") {
+		return false
+	}
+
+	_, err := parseUSBID(s)
+	return err != nil
+}
+
+// Vendor and product IDs are hexadecimal, as printed by lsusb
+func parseUSBID(s string) (uint16, error) {
+	v, err := strconv.ParseUint(strings.TrimPrefix(strings.ToLower(s), "0x"), 16, 16)
+	return uint16(v), err
+}
+
+func lookupName[T comparable](names map[T]string, name string) (T, bool) {
+	for v, n := range names {
+		if strings.EqualFold(n, name) {
+			return v, true
+		}
+	}
+
+	var zero T
+	return zero, false
+}
+
+// Encodes the durations that differ from their default value
+func encodeDurations(query url.Values, params map[string][2]time.Duration) string {
+	for key, d := range params {
+		if d[0] != d[1] {
+			query.Set(key, d[0].String())
+		}
+	}
+
+	if len(query) == 0 {
+		return ""
+	}
+
+	return "?" + query.Encode()
+}
+
+// Only the settings that differ from their default value are encoded,
+// zero values stand for the default
+func (c *SerialConfig) String() string {
+	defaults := &SerialConfig{}
+	defaults.Default()
+
+	query := url.Values{}
+	if c.BaudRate != 0 && c.BaudRate != defaults.BaudRate {
+		query.Set("baud", strconv.Itoa(c.BaudRate))
+	}
+	if c.Parity != defaults.Parity {
+		query.Set("parity", parityNames[c.Parity])
+	}
+	if c.DataBits != 0 && c.DataBits != defaults.DataBits {
+		query.Set("databits", strconv.Itoa(c.DataBits))
+	}
+	if c.StopBits != defaults.StopBits {
+		query.Set("stopbits", stopBitsNames[c.StopBits])
+	}
+	if c.FlowControl != defaults.FlowControl {
+		query.Set("flow", flowControlNames[c.FlowControl])
+	}
+
+	u := url.URL{Scheme: "serial", RawQuery: query.Encode()}
+	if strings.HasPrefix(c.Port, "/") {
+		u.Path = c.Port
+	} else {
+		u.Host = c.Port
+	}
+
+	return u.String()
+}
+
+func (c *TCPConfig) String() string {
+	defaults := &TCPConfig{}
+	defaults.Default()
+
+	port := c.Port
+	if port == 0 {
+		port = defaults.Port
+	}
+
+	return "tcp://" + net.JoinHostPort(c.Host, strconv.Itoa(port)) + encodeDurations(url.Values{}, map[string][2]time.Duration{
+		"dial_timeout":  {c.DialTimeout, defaults.DialTimeout},
+		"read_timeout":  {c.ReadTimeout, defaults.ReadTimeout},
+		"write_timeout": {c.WriteTimeout, defaults.WriteTimeout},
+		"keepalive":     {c.KeepAlive, defaults.KeepAlive},
+	})
+}
+
+func (c *USBConfig) String() string {
+	defaults := &USBConfig{}
+	defaults.Default()
+
+	var target string
+	switch {
+	case c.Device != "":
+		target = c.Device
+	case c.VendorID != 0 || c.ProductID != 0 || c.Serial != "":
+		target = fmt.Sprintf("%04x:%04x", c.VendorID, c.ProductID)
+		if c.Serial != "" {
+			target += "/" + url.PathEscape(c.Serial)
+		}
+	}
+
+	return "usb://" + target + encodeDurations(url.Values{}, map[string][2]time.Duration{
+		"read_timeout":  {c.ReadTimeout, defaults.ReadTimeout},
+		"write_timeout": {c.WriteTimeout, defaults.WriteTimeout},
+	})
+}
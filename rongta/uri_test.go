@@ -0,0 +1,201 @@
+package rongta
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.bug.st/serial"
+)
+
+func defaultSerial(modify func(c *SerialConfig)) *SerialConfig {
+	c := &SerialConfig{}
+	c.Default()
+	modify(c)
+
+	return c
+}
+
+func defaultTCP(modify func(c *TCPConfig)) *TCPConfig {
+	c := &TCPConfig{}
+	c.Default()
+	modify(c)
+
+	return c
+}
+
+func defaultUSB(modify func(c *USBConfig)) *USBConfig {
+	c := &USBConfig{}
+	c.Default()
+	modify(c)
+
+	return c
+}
+
+func TestParseURI(t *testing.T) {
+	for _, tt := range []struct {
+		uri  string
+		want Config
+	}{
+		{"serial:///dev/ttyUSB0", defaultSerial(func(c *SerialConfig) {
+			c.Port = "/dev/ttyUSB0"
+		})},
+		{"serial://COM1?baud=9600", defaultSerial(func(c *SerialConfig) {
+			c.Port = "COM1"
+			c.BaudRate = 9600
+		})},
+		{"SERIAL:///dev/ttyS0?baud=115200&parity=even&databits=7&stopbits=2", defaultSerial(func(c *SerialConfig) {
+			c.Port = "/dev/ttyS0"
+			c.BaudRate = 115200
+			c.Parity = serial.EvenParity
+			c.DataBits = 7
+			c.StopBits = serial.TwoStopBits
+		})},
+		{"serial:///dev/ttyUSB0?flow=RTSCTS", defaultSerial(func(c *SerialConfig) {
+			c.Port = "/dev/ttyUSB0"
+			c.FlowControl = RTSCTSFlowControl
+		})},
+		{"tcp://10.0.0.5", defaultTCP(func(c *TCPConfig) {
+			c.Host = "10.0.0.5"
+		})},
+		{"tcp://[::1]:9101/?read_timeout=1s&keepalive=-1s", defaultTCP(func(c *TCPConfig) {
+			c.Host = "::1"
+			c.Port = 9101
+			c.ReadTimeout = time.Second
+			c.KeepAlive = -time.Second
+		})},
+		{"usb://", defaultUSB(func(c *USBConfig) {})},
+		{"usb:///dev/usb/lp1", defaultUSB(func(c *USBConfig) {
+			c.Device = "/dev/usb/lp1"
+		})},
+		{"usb://lp0", defaultUSB(func(c *USBConfig) {
+			c.Device = "/dev/usb/lp0"
+		})},
+		{"usb://0FE6:811e/AB%2F12?write_timeout=2s", defaultUSB(func(c *USBConfig) {
+			c.VendorID = 0x0fe6
+			c.ProductID = 0x811e
+			c.Serial = "AB/12"
+			c.WriteTimeout = 2 * time.Second
+		})},
+	} {
+		got, err := ParseURI(tt.uri)
+		if err != nil {
+			t.Errorf("%s: %v", tt.uri, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %+v, want %+v", tt.uri, got, tt.want)
+		}
+	}
+}
+
+func TestParseURIErrors(t *testing.T) {
+	for _, tt := range []struct {
+		uri  string
+		want error
+	}{
+		{"/dev/ttyUSB0", ErrInvalidURI},
+		{"lpt://1", ErrUnsupportedScheme},
+
+		{"serial://", ErrInvalidURI},
+		{"serial://dev/ttyUSB0", ErrInvalidURI},
+		{"serial:///dev/ttyUSB0?baud=0", ErrInvalidURI},
+		{"serial:///dev/ttyUSB0?baud=fast", ErrInvalidURI},
+		{"serial:///dev/ttyUSB0?parity=weird", ErrInvalidURI},
+		{"serial:///dev/ttyUSB0?databits=9", ErrInvalidURI},
+		{"serial:///dev/ttyUSB0?stopbits=3", ErrInvalidURI},
+		{"serial:///dev/ttyUSB0?flow=dtrdsr", ErrInvalidURI},
+		{"serial:///dev/ttyUSB0?speed=9600", ErrInvalidURI},
+
+		{"tcp://", ErrInvalidURI},
+		{"tcp://printer:0", ErrInvalidURI},
+		{"tcp://printer:70000", ErrInvalidURI},
+		{"tcp://printer/queue", ErrInvalidURI},
+		{"tcp://printer?read_timeout=5", ErrInvalidURI},
+		{"tcp://printer?timeout=5s", ErrInvalidURI},
+
+		{"usb://0fe6", ErrInvalidURI},
+		{"usb://0fe6:zzzz", ErrInvalidURI},
+		{"usb://10000:811e", ErrInvalidURI},
+		{"usb://lp0?read_timeout=soon", ErrInvalidURI},
+		{"usb://lp0?baud=9600", ErrInvalidURI},
+	} {
+		if _, err := ParseURI(tt.uri); !errors.Is(err, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.uri, err, tt.want)
+		}
+	}
+}
+
+func TestURIRoundTrip(t *testing.T) {
+	for _, c := range []Config{
+		defaultSerial(func(c *SerialConfig) {}),
+		defaultSerial(func(c *SerialConfig) {
+			c.Port = "COM3"
+			c.BaudRate = 9600
+		}),
+		defaultSerial(func(c *SerialConfig) {
+			c.Port = "/dev/ttyS1"
+			c.Parity = serial.OddParity
+			c.DataBits = 7
+			c.StopBits = serial.OnePointFiveStopBits
+		}),
+		defaultSerial(func(c *SerialConfig) {
+			c.Port = "/dev/ttyS2"
+			c.FlowControl = XONXOFFFlowControl
+		}),
+		defaultTCP(func(c *TCPConfig) {}),
+		defaultTCP(func(c *TCPConfig) {
+			c.Host = "fe80::1"
+			c.Port = 9101
+			c.DialTimeout = time.Second
+			c.WriteTimeout = 0
+		}),
+		defaultUSB(func(c *USBConfig) {}),
+		defaultUSB(func(c *USBConfig) {
+			c.Device = "/dev/usb/lp2"
+		}),
+		defaultUSB(func(c *USBConfig) {
+			c.VendorID = 0x0fe6
+			c.ProductID = 0x811e
+			c.Serial = "SN 42/A"
+			c.ReadTimeout = 0
+		}),
+	} {
+		uri := c.String()
+
+		got, err := ParseURI(uri)
+		if err != nil {
+			t.Errorf("%+v: %s: %v", c, uri, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(got, c) {
+			t.Errorf("%s: got %+v, want %+v", uri, got, c)
+		}
+	}
+}
+
+// Zero values stand for the defaults, they aren't written out
+func TestSerialURIOmitsDefaults(t *testing.T) {
+	c := &SerialConfig{Port: "/dev/ttyUSB0", BaudRate: 9600}
+
+	uri := c.String()
+	if uri != "serial:///dev/ttyUSB0?baud=9600" {
+		t.Errorf("got %s, want serial:///dev/ttyUSB0?baud=9600", uri)
+	}
+
+	got, err := ParseURI(uri)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := defaultSerial(func(c *SerialConfig) {
+		c.Port = "/dev/ttyUSB0"
+		c.BaudRate = 9600
+	})
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
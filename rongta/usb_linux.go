@@ -0,0 +1,124 @@
+package rongta
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrUSBPrinterNotFound = errors.New("no matching USB printer found")
+)
+
+// Open the usblp device node
+// Returns a ReadWriteCloser interface
+func (c *USBConfig) connect() (io.ReadWriteCloser, error) {
+	device := c.Device
+	if device == "" {
+		var err error
+		device, err = c.find()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	f, err := os.OpenFile(device, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return &deadlineConn{
+		conn:         f,
+		readTimeout:  c.ReadTimeout,
+		writeTimeout: c.WriteTimeout,
+	}, nil
+}
+
+// Walks <SysfsRoot>/class/usbmisc/lpN and returns the device node of the
+// first printer whose USB descriptors match the config
+func (c *USBConfig) find() (string, error) {
+	sysfsRoot := c.SysfsRoot
+	if sysfsRoot == "" {
+		sysfsRoot = "/sys"
+	}
+
+	devRoot := c.DevRoot
+	if devRoot == "" {
+		devRoot = "/dev/usb"
+	}
+
+	classDir := filepath.Join(sysfsRoot, "class", "usbmisc")
+	entries, err := os.ReadDir(classDir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", ErrUSBPrinterNotFound
+		}
+		return "", err
+	}
+
+	for _, e := range entries {
+		if !strings.HasPrefix(e.Name(), "lp") {
+			continue
+		}
+
+		// lpN/device points at the printer interface, the USB device
+		// descriptors live in its parent directory
+		iface, err := filepath.EvalSymlinks(filepath.Join(classDir, e.Name(), "device"))
+		if err != nil {
+			continue
+		}
+		usbDir := filepath.Dir(iface)
+
+		if c.VendorID != 0 {
+			vid, err := readSysfsHex(usbDir, "idVendor")
+			if err != nil || vid != c.VendorID {
+				continue
+			}
+		}
+
+		if c.ProductID != 0 {
+			pid, err := readSysfsHex(usbDir, "idProduct")
+			if err != nil || pid != c.ProductID {
+				continue
+			}
+		}
+
+		if c.Serial != "" {
+			serial, err := readSysfs(usbDir, "serial")
+			if err != nil || serial != c.Serial {
+				continue
+			}
+		}
+
+		return filepath.Join(devRoot, e.Name()), nil
+	}
+
+	return "", ErrUSBPrinterNotFound
+}
+
+func readSysfs(dir, name string) (string, error) {
+	b, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(b)), nil
+}
+
+func readSysfsHex(dir, name string) (uint16, error) {
+	s, err := readSysfs(dir, name)
+	if err != nil {
+		return 0, err
+	}
+
+	v, err := strconv.ParseUint(s, 16, 16)
+	if err != nil {
+		return 0, fmt.Errorf("%s/%s: %w", dir, name, err)
+	}
+
+	return uint16(v), nil
+}
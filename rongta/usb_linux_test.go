@@ -0,0 +1,147 @@
+package rongta
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type fakeUSBPrinter struct {
+	node      string // lpN
+	device    string // USB device directory name, e.g. 1-1
+	vendorID  string
+	productID string
+	serial    string
+}
+
+// Builds the sysfs layout of usblp printers under a temporary root
+func fakeSysfs(t *testing.T, printers ...fakeUSBPrinter) string {
+	t.Helper()
+
+	root := t.TempDir()
+	classDir := filepath.Join(root, "class", "usbmisc")
+
+	// Other usbmisc devices are skipped
+	mkdir(t, filepath.Join(classDir, "hiddev0"))
+
+	for _, p := range printers {
+		usbDir := filepath.Join(root, "devices", "pci0000:00", "usb1", p.device)
+		iface := filepath.Join(usbDir, p.device+":1.0")
+		mkdir(t, iface)
+
+		writeFile(t, filepath.Join(usbDir, "idVendor"), p.vendorID+"\n")
+		writeFile(t, filepath.Join(usbDir, "idProduct"), p.productID+"\n")
+		if p.serial != "" {
+			writeFile(t, filepath.Join(usbDir, "serial"), p.serial+"\n")
+		}
+
+		mkdir(t, filepath.Join(classDir, p.node))
+		target, err := filepath.Rel(filepath.Join(classDir, p.node), iface)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.Symlink(target, filepath.Join(classDir, p.node, "device")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return root
+}
+
+func mkdir(t *testing.T, dir string) {
+	t.Helper()
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUSBFind(t *testing.T) {
+	root := fakeSysfs(t,
+		fakeUSBPrinter{node: "lp0", device: "1-1", vendorID: "04b8", productID: "0202", serial: "EPSON1"},
+		fakeUSBPrinter{node: "lp1", device: "1-2", vendorID: "0fe6", productID: "811e", serial: "RP326A"},
+		fakeUSBPrinter{node: "lp2", device: "1-3", vendorID: "0fe6", productID: "811e", serial: "RP326B"},
+	)
+
+	for _, tt := range []struct {
+		name   string
+		config USBConfig
+		want   string
+		err    error
+	}{
+		{"any", USBConfig{}, "lp0", nil},
+		{"vendor", USBConfig{VendorID: 0x0fe6}, "lp1", nil},
+		{"vendor and product", USBConfig{VendorID: 0x0fe6, ProductID: 0x811e}, "lp1", nil},
+		{"serial", USBConfig{VendorID: 0x0fe6, Serial: "RP326B"}, "lp2", nil},
+		{"no match", USBConfig{VendorID: 0x1234}, "", ErrUSBPrinterNotFound},
+		{"unknown serial", USBConfig{Serial: "nope"}, "", ErrUSBPrinterNotFound},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			config := tt.config
+			config.SysfsRoot = root
+			config.DevRoot = "/dev/usb"
+
+			got, err := config.find()
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+
+			if tt.want != "" && got != filepath.Join("/dev/usb", tt.want) {
+				t.Errorf("found %s, want /dev/usb/%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUSBFindWithoutPrinterClass(t *testing.T) {
+	config := USBConfig{SysfsRoot: t.TempDir()}
+
+	if _, err := config.find(); !errors.Is(err, ErrUSBPrinterNotFound) {
+		t.Errorf("got %v, want ErrUSBPrinterNotFound", err)
+	}
+}
+
+func TestUSBConnectWritesToFoundNode(t *testing.T) {
+	root := fakeSysfs(t, fakeUSBPrinter{node: "lp3", device: "2-1", vendorID: "0fe6", productID: "811e"})
+
+	devRoot := t.TempDir()
+	node := filepath.Join(devRoot, "lp3")
+	writeFile(t, node, "")
+
+	config := USBConfig{}
+	config.Default()
+	config.SysfsRoot = root
+	config.DevRoot = devRoot
+	config.VendorID = 0x0fe6
+
+	rwc, err := config.connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Regular files don't support deadlines, the write must go through
+	// regardless
+	if _, err := rwc.Write([]byte{0x1B, '@'}); err != nil {
+		t.Fatal(err)
+	}
+	rwc.Close()
+
+	b, err := os.ReadFile(node)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(b) != "\x1b@" {
+		t.Errorf("device received % x, want 1b 40", b)
+	}
+}
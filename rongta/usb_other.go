@@ -0,0 +1,17 @@
+//go:build !linux
+
+package rongta
+
+import (
+	"errors"
+	"io"
+)
+
+var (
+	ErrUSBPrinterNotFound = errors.New("no matching USB printer found")
+	ErrUSBUnsupported     = errors.New("USB printer-class devices are only supported on Linux")
+)
+
+func (c *USBConfig) connect() (io.ReadWriteCloser, error) {
+	return nil, ErrUSBUnsupported
+}
@@ -1 +1,110 @@
 package rongta
+
+import (
+	"errors"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Implemented by net.Conn and pollable *os.File
+type deadlineReadWriteCloser interface {
+	io.ReadWriteCloser
+	SetReadDeadline(t time.Time) error
+	SetWriteDeadline(t time.Time) error
+}
+
+// deadlineConn applies the configured read/write timeouts to every operation.
+// Deadlines set explicitly through SetReadDeadline/SetWriteDeadline take
+// precedence when they expire earlier than the configured timeout.
+type deadlineConn struct {
+	conn         deadlineReadWriteCloser
+	readTimeout  time.Duration
+	writeTimeout time.Duration
+
+	mu            sync.Mutex
+	readDeadline  time.Time
+	writeDeadline time.Time
+}
+
+func (c *deadlineConn) Read(b []byte) (int, error) {
+	c.mu.Lock()
+	deadline := earliest(c.readDeadline, c.readTimeout)
+	c.mu.Unlock()
+
+	// Files that can't be polled don't support deadlines, block instead
+	if err := c.conn.SetReadDeadline(deadline); err != nil && !errors.Is(err, os.ErrNoDeadline) {
+		return 0, err
+	}
+
+	return c.conn.Read(b)
+}
+
+func (c *deadlineConn) Write(b []byte) (int, error) {
+	c.mu.Lock()
+	deadline := earliest(c.writeDeadline, c.writeTimeout)
+	c.mu.Unlock()
+
+	// Files that can't be polled don't support deadlines, block instead
+	if err := c.conn.SetWriteDeadline(deadline); err != nil && !errors.Is(err, os.ErrNoDeadline) {
+		return 0, err
+	}
+
+	return c.conn.Write(b)
+}
+
+func (c *deadlineConn) Close() error {
+	return c.conn.Close()
+}
+
+func (c *deadlineConn) SetReadDeadline(t time.Time) error {
+	c.mu.Lock()
+	c.readDeadline = t
+	c.mu.Unlock()
+
+	return c.conn.SetReadDeadline(t)
+}
+
+func (c *deadlineConn) SetWriteDeadline(t time.Time) error {
+	c.mu.Lock()
+	c.writeDeadline = t
+	c.mu.Unlock()
+
+	return c.conn.SetWriteDeadline(t)
+}
+
+// Returns the earliest of an explicit deadline and now + timeout.
+// A zero deadline and a zero timeout both mean "no limit".
+func earliest(deadline time.Time, timeout time.Duration) time.Time {
+	if timeout <= 0 {
+		return deadline
+	}
+
+	d := time.Now().Add(timeout)
+	if !deadline.IsZero() && deadline.Before(d) {
+		return deadline
+	}
+
+	return d
+}
+
+// Forwards a read deadline to connections that support it
+func setReadDeadline(rwc io.ReadWriteCloser, t time.Time) error {
+	d, ok := rwc.(interface{ SetReadDeadline(time.Time) error })
+	if !ok {
+		return os.ErrNoDeadline
+	}
+
+	return d.SetReadDeadline(t)
+}
+
+// Forwards a write deadline to connections that support it
+func setWriteDeadline(rwc io.ReadWriteCloser, t time.Time) error {
+	d, ok := rwc.(interface{ SetWriteDeadline(time.Time) error })
+	if !ok {
+		return os.ErrNoDeadline
+	}
+
+	return d.SetWriteDeadline(t)
+}
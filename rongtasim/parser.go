@@ -0,0 +1,113 @@
+package rongtasim
+
+import (
+	"errors"
+
+	"github.com/cyb3rjerry/rongta-escpos/commands"
+)
+
+// Executes the command at the start of b
+// Returns the number of bytes consumed, 0 when the command is incomplete.
+// Unknown commands are skipped.
+func (p *Printer) execute(b []byte) int {
+	cmd, n, err := commands.DecodeNext(b)
+	if errors.Is(err, commands.ErrTruncatedCommand) {
+		return 0
+	}
+
+	switch cmd.Name {
+	case "Text":
+		for _, c := range []byte(cmd.Args[0].(string)) {
+			switch c {
+			case commands.LF:
+				p.printLine()
+			case commands.CR:
+			default:
+				p.line.WriteByte(c)
+			}
+		}
+	case "PrintBufferAndReturnToStandardMode":
+		p.flushLine()
+		p.state.PageMode = false
+	case "CancelPrintData":
+		if p.state.PageMode {
+			p.line.Reset()
+		}
+	case "Initialize":
+		p.initialize()
+	case "SetDefaultLineSpacing":
+		p.state.LineSpacing = 0
+	case "SetLineSpacing":
+		p.state.LineSpacing = cmd.Args[0].(uint8)
+	case "PrintBufferInPageMode", "PrintAndFeedNLines", "PrintAndFeedNDotsLines":
+		p.flushLine()
+	case "SelectPageMode":
+		p.flushLine()
+		p.state.PageMode = true
+	case "SelectStandardMode":
+		p.state.PageMode = false
+	case "Cut", "CutPaper", "SelectCutModeAndCutPaper":
+		p.cut()
+	case "SetPrintMode":
+		p.setPrintMode(cmd.Raw[2])
+	case "SetUnderline":
+		p.state.Underline = cmd.Args[0].(commands.Underline)
+	case "SetEmphasizedMode":
+		p.state.Emphasized = cmd.Args[0].(uint8)&0x01 != 0
+	case "SetDoubleStrikeMode":
+		p.state.DoubleStrike = cmd.Args[0].(uint8)&0x01 != 0
+	case "SetCharacterFont":
+		p.state.Font = cmd.Args[0].(commands.Font)
+	case "RotateClockwise90Degrees":
+		p.state.Rotated = cmd.Args[0].(bool)
+	case "SetJustification":
+		p.state.Justification = cmd.Args[0].(commands.Justify)
+	case "SelectInternationalCharacterSet":
+		p.state.CharacterSet = uint8(cmd.Args[0].(commands.CharacterSet))
+	case "SelectCharacterSize":
+		p.state.CharSize = cmd.Raw[2]
+	case "SetWhiteBlackReversePrintingMode":
+		p.state.Reverse = cmd.Args[0].(uint8)&0x01 != 0
+	case "SetLeftMargin":
+		p.state.LeftMargin = uint16(word(cmd.Raw[2], cmd.Raw[3]))
+	case "SetPrintingAreaWidth":
+		p.state.PrintingWidth = uint16(word(cmd.Raw[2], cmd.Raw[3]))
+	case "ToggleASB":
+		p.state.ASB = cmd.Args[0].(uint8)
+	case "TransmitStatus":
+		p.reply(p.paperStatus)
+	case "TransmitPrinterID":
+		id, ok := p.ids[cmd.Raw[2]]
+		if !ok {
+			id = block("")
+		}
+		p.reply(id...)
+	case "TransmitRealTimeStatus":
+		if int(cmd.Raw[2]) < len(p.status) {
+			p.reply(p.status[cmd.Raw[2]])
+		}
+	}
+
+	return n
+}
+
+func (p *Printer) setPrintMode(n uint8) {
+	p.state.PrintMode = n
+	p.state.Font = commands.Font(n&0x01 != 0)
+	p.state.Emphasized = n&0x08 != 0
+
+	if n&0x80 != 0 {
+		p.state.Underline = commands.UnderlineThin
+	} else {
+		p.state.Underline = commands.UnderlineNone
+	}
+}
+
+func (p *Printer) cut() {
+	p.flushLine()
+	p.cuts++
+}
+
+func word(l, h uint8) int {
+	return int(l) + int(h)*256
+}
@@ -0,0 +1,333 @@
+// Package rongtasim implements an in-memory Rongta RP32x printer.
+//
+// The virtual printer is an io.ReadWriteCloser that can be handed to
+// commands.NewDriver. It parses the command stream, keeps track of the
+// printer state and the printed text, and answers status requests with
+// configurable replies.
+package rongtasim
+
+import (
+	"bytes"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/cyb3rjerry/rongta-escpos/commands"
+	"github.com/cyb3rjerry/rongta-escpos/internal/replies"
+)
+
+// Printer settings changed by the command stream
+type State struct {
+	PrintMode     uint8 // ESC !
+	Font          commands.Font
+	Justification commands.Justify
+	Underline     commands.Underline
+	Emphasized    bool
+	DoubleStrike  bool
+	Reverse       bool
+	Rotated       bool
+	CharSize      uint8 // GS !
+	LineSpacing   uint8 // ESC 3, 0 is the default spacing
+	LeftMargin    uint16
+	PrintingWidth uint16 // GS W, 0 is the full width
+	PageMode      bool
+	CharacterSet  uint8
+	ASB           uint8
+}
+
+// Data received between two paper cuts
+type Job struct {
+	Lines []string // Printed text lines
+	Raw   []byte   // Bytes received, up to and including the cut
+}
+
+type Printer struct {
+	mu sync.Mutex
+
+	state   State
+	line    bytes.Buffer // Text not printed yet
+	lines   []string     // Printed text lines
+	cuts    int
+	raw     []byte
+	pending []byte // Incomplete command waiting for more bytes
+
+	jobs      []Job
+	jobLine   int // First line of the current job
+	jobOffset int // First raw byte of the current job
+
+	status      [5]byte // DLE EOT n replies, indexed by n
+	paperStatus byte    // GS r replies
+	ids         map[uint8][]byte
+
+	replies *replies.Queue
+	closed  bool
+}
+
+// Initialize a virtual printer that is online, with the cover closed and
+// paper loaded
+func New() *Printer {
+	p := &Printer{
+		ids:     map[uint8][]byte{},
+		replies: replies.NewQueue(),
+	}
+
+	for n := range p.status {
+		p.status[n] = commands.REALTIME_STATUS_FIXED_BITS
+	}
+
+	for n, id := range defaultIDs {
+		p.ids[n] = id
+	}
+
+	return p
+}
+
+// Replies to GS I n of a new virtual printer, an RP326 with a cutter
+var defaultIDs = map[uint8][]byte{
+	1:  {0x20},
+	2:  {commands.TYPE_ID_CUTTER_MASK},
+	65: block("1.00"),
+	66: block("RONGTA"),
+	67: block("RP326"),
+	68: block("SIM00001"),
+}
+
+// Frames a printer ID the way GS I n = 65 to 68 are answered
+func block(s string) []byte {
+	return append(append([]byte{commands.BLOCK_REPLY_HEADER}, s...), commands.NUL)
+}
+
+// Sets the reply to DLE EOT n (1 <= n <= 4)
+// The fixed bits are the caller's responsibility, so line noise can be
+// simulated.
+func (p *Printer) SetStatus(n uint8, b byte) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if int(n) < len(p.status) {
+		p.status[n] = b
+	}
+}
+
+// Sets the raw reply to GS r
+func (p *Printer) SetPaperStatus(b byte) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.paperStatus = b
+}
+
+// Sets the paper roll sensor, reported by both DLE EOT 4 and GS r 1 in
+// their own layouts
+func (p *Printer) SetPaper(s commands.PaperStatus) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	realTime, sensor := commands.REALTIME_STATUS_FIXED_BITS, uint8(0)
+
+	switch s {
+	case commands.PaperStatusLow:
+		realTime |= commands.PAPER_NEAR_END_STATUS_MASK
+		sensor |= commands.PAPER_SENSOR_NEAR_END_MASK
+	case commands.PaperStatusOut:
+		realTime |= commands.PAPER_NEAR_END_STATUS_MASK | commands.PAPER_PRESENT_STATUS_MASK
+		sensor |= commands.PAPER_SENSOR_NEAR_END_MASK | commands.PAPER_SENSOR_END_MASK
+	}
+
+	p.status[4] = realTime
+	p.paperStatus = sensor
+}
+
+// Sets the reply to a printer ID request for n
+// IDs that are neither set nor part of the defaults are answered with an
+// empty block.
+func (p *Printer) SetPrinterID(n uint8, reply []byte) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.ids[n] = append([]byte(nil), reply...)
+}
+
+// Queues unsolicited bytes (e.g. ASB frames) for the host to read
+func (p *Printer) Inject(b []byte) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.reply(b...)
+}
+
+// Returns a copy of the current printer state
+func (p *Printer) State() State {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.state
+}
+
+// Returns the printed text lines
+func (p *Printer) Lines() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return append([]string(nil), p.lines...)
+}
+
+// Returns the text waiting in the print buffer
+func (p *Printer) Buffer() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.line.String()
+}
+
+// Returns the number of paper cuts
+func (p *Printer) Cuts() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.cuts
+}
+
+// Returns the jobs finished by a cut since the last call
+func (p *Printer) TakeJobs() []Job {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	jobs := p.jobs
+	p.jobs = nil
+
+	return jobs
+}
+
+// Prints the buffer and finishes the current job without a cut, as when
+// the host disconnects mid-job
+// Returns false when nothing was received since the last job.
+func (p *Printer) EndJob() (Job, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.flushLine()
+
+	end := len(p.raw) - len(p.pending)
+	if end == p.jobOffset && len(p.lines) == p.jobLine {
+		return Job{}, false
+	}
+
+	p.finishJob(end)
+	job := p.jobs[len(p.jobs)-1]
+	p.jobs = p.jobs[:len(p.jobs)-1]
+
+	return job, true
+}
+
+// Returns every byte received so far
+func (p *Printer) Raw() []byte {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return append([]byte(nil), p.raw...)
+}
+
+// Receives data from the host
+// Commands split across writes are kept until the rest arrives. A real-time
+// status request written on its own in the middle of such a command is
+// answered on reception, as the printer does between paced chunks.
+func (p *Printer) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return 0, os.ErrClosed
+	}
+
+	// Kept out of Raw, the job data is the split command
+	if len(p.pending) > 0 && len(b) == 3 && b[0] == commands.DLE && b[1] == commands.EOT {
+		if int(b[2]) < len(p.status) {
+			p.reply(p.status[b[2]])
+		}
+		return len(b), nil
+	}
+
+	p.raw = append(p.raw, b...)
+	p.pending = append(p.pending, b...)
+
+	for len(p.pending) > 0 {
+		cuts := p.cuts
+
+		n := p.execute(p.pending)
+		if n == 0 {
+			break
+		}
+		p.pending = p.pending[n:]
+
+		if p.cuts != cuts {
+			p.finishJob(len(p.raw) - len(p.pending))
+		}
+	}
+
+	return len(b), nil
+}
+
+// Returns the printer's replies, blocking until one is available, the read
+// deadline expires or the printer is closed
+func (p *Printer) Read(b []byte) (int, error) {
+	return p.replies.Read(b)
+}
+
+func (p *Printer) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return os.ErrClosed
+	}
+
+	p.closed = true
+	p.replies.Close()
+
+	return nil
+}
+
+func (p *Printer) SetReadDeadline(t time.Time) error {
+	return p.replies.SetReadDeadline(t)
+}
+
+// Writes never block
+func (p *Printer) SetWriteDeadline(t time.Time) error {
+	return nil
+}
+
+func (p *Printer) reply(b ...byte) {
+	p.replies.Push(b...)
+}
+
+// Moves the print buffer to the printed lines
+func (p *Printer) printLine() {
+	p.lines = append(p.lines, p.line.String())
+	p.line.Reset()
+}
+
+// Prints the buffer only when it holds data, used by commands that print
+// and feed
+func (p *Printer) flushLine() {
+	if p.line.Len() > 0 {
+		p.printLine()
+	}
+}
+
+// Closes the current job at raw offset end
+func (p *Printer) finishJob(end int) {
+	p.jobs = append(p.jobs, Job{
+		Lines: append([]string(nil), p.lines[p.jobLine:]...),
+		Raw:   append([]byte(nil), p.raw[p.jobOffset:end]...),
+	})
+
+	p.jobLine = len(p.lines)
+	p.jobOffset = end
+}
+
+// Resets the state like ESC @, the replies configuration is kept
+func (p *Printer) initialize() {
+	p.state = State{}
+	p.line.Reset()
+}
@@ -0,0 +1,181 @@
+package rongtasim_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/cyb3rjerry/rongta-escpos/commands"
+	"github.com/cyb3rjerry/rongta-escpos/rongtasim"
+)
+
+func newDriver(t *testing.T) (*commands.Driver, *rongtasim.Printer) {
+	t.Helper()
+
+	sim := rongtasim.New()
+	t.Cleanup(func() { sim.Close() })
+
+	return commands.NewDriver(sim), sim
+}
+
+func must(t *testing.T, err error) {
+	t.Helper()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestState(t *testing.T) {
+	d, sim := newDriver(t)
+
+	must(t, d.SetJustification(commands.JustifyCenter))
+	must(t, d.SetEmphasizedMode(1))
+	must(t, d.SetUnderline(commands.UnderlineThick))
+	must(t, d.SetCharacterFont(commands.FontB))
+	must(t, d.SetLineSpacing(40))
+	must(t, d.SetLeftMargin(0x10, 0x01))
+	must(t, d.SelectPageMode())
+	must(t, d.ToggleASB(0x0c))
+
+	want := rongtasim.State{
+		Font:          commands.FontB,
+		Justification: commands.JustifyCenter,
+		Underline:     commands.UnderlineThick,
+		Emphasized:    true,
+		LineSpacing:   40,
+		LeftMargin:    0x110,
+		PageMode:      true,
+		ASB:           0x0c,
+	}
+
+	if got := sim.State(); got != want {
+		t.Errorf("got state %+v, want %+v", got, want)
+	}
+
+	must(t, d.SelectStandardMode())
+	if sim.State().PageMode {
+		t.Error("still in page mode")
+	}
+
+	must(t, d.Initialize())
+	if got := sim.State(); got != (rongtasim.State{}) {
+		t.Errorf("got state %+v after Initialize, want the defaults", got)
+	}
+}
+
+func TestPrintMode(t *testing.T) {
+	d, sim := newDriver(t)
+
+	must(t, d.SetPrintMode(&commands.PrintMode{Font: commands.FontB, IsEmphasized: true}))
+
+	state := sim.State()
+	if state.Font != commands.FontB || !state.Emphasized {
+		t.Errorf("got state %+v, want font B and emphasized", state)
+	}
+}
+
+func TestLines(t *testing.T) {
+	d, sim := newDriver(t)
+
+	must(t, d.WriteStringToBuffer("first line\nsecond"))
+
+	if got := sim.Lines(); len(got) != 1 || got[0] != "first line" {
+		t.Errorf("printed %q, want [first line]", got)
+	}
+
+	if got := sim.Buffer(); got != "second" {
+		t.Errorf("buffer holds %q, want second", got)
+	}
+
+	// Print and feed prints the buffer
+	must(t, d.PrintAndFeedNLines(3))
+
+	if got := sim.Lines(); len(got) != 2 || got[1] != "second" {
+		t.Errorf("printed %q, want [first line second]", got)
+	}
+}
+
+func TestCommandsSplitAcrossWrites(t *testing.T) {
+	sim := rongtasim.New()
+	defer sim.Close()
+
+	// ESC a 1, then ESC 3 40 cut in the middle
+	for _, b := range [][]byte{{commands.ESC}, {'a', 1, commands.ESC, '3'}, {40}} {
+		if _, err := sim.Write(b); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	state := sim.State()
+	if state.Justification != commands.JustifyCenter || state.LineSpacing != 40 {
+		t.Errorf("got state %+v, want centered with a spacing of 40", state)
+	}
+
+	want := []byte{commands.ESC, 'a', 1, commands.ESC, '3', 40}
+	if got := sim.Raw(); !bytes.Equal(got, want) {
+		t.Errorf("raw bytes % x, want % x", got, want)
+	}
+}
+
+func TestCuts(t *testing.T) {
+	d, sim := newDriver(t)
+
+	must(t, d.WriteStringToBuffer("receipt 1\n"))
+	must(t, d.Cut())
+	must(t, d.WriteStringToBuffer("receipt 2\n"))
+	must(t, d.Cut())
+
+	if n := sim.Cuts(); n != 2 {
+		t.Errorf("%d cuts, want 2", n)
+	}
+}
+
+func TestStatusReplies(t *testing.T) {
+	d, sim := newDriver(t)
+
+	jammed, err := d.GetAutocutterStatus()
+	must(t, err)
+	if jammed {
+		t.Error("new printer reports a jammed autocutter")
+	}
+
+	sim.SetStatus(3, commands.REALTIME_STATUS_FIXED_BITS|commands.AUTOCUTER_STATUS_MASK)
+	sim.SetPaper(commands.PaperStatusOut)
+
+	jammed, err = d.GetAutocutterStatus()
+	must(t, err)
+	if !jammed {
+		t.Error("autocutter reported fine")
+	}
+
+	paper, err := d.TransmitStatus()
+	must(t, err)
+	if paper != commands.PaperStatusOut {
+		t.Errorf("GS r 1 reports %s, want out", paper)
+	}
+}
+
+func TestInjectedBytes(t *testing.T) {
+	sim := rongtasim.New()
+	defer sim.Close()
+
+	frame := []byte{0x10 | 0x20, 0x00, 0x00, 0x00}
+	sim.Inject(frame)
+
+	sim.SetReadDeadline(time.Now().Add(time.Second))
+
+	got := make([]byte, 8)
+	n, err := sim.Read(got)
+	must(t, err)
+
+	if !bytes.Equal(got[:n], frame) {
+		t.Errorf("read % x, want % x", got[:n], frame)
+	}
+
+	// Nothing else to read
+	sim.SetReadDeadline(time.Now().Add(10 * time.Millisecond))
+	if n, err := sim.Read(got); err == nil {
+		t.Errorf("read % x, want a timeout", got[:n])
+	}
+}